@@ -22,17 +22,28 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/cloudbridgeuy/scripts/pkg/errors"
 	"github.com/cloudbridgeuy/scripts/pkg/logger"
 	"github.com/cloudbridgeuy/scripts/pkg/markdown"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var markdownCmd = &cobra.Command{
@@ -47,7 +58,10 @@ document's external links and images.
 The HTML is written beside the source file with a .html extension by default.
 Use --output to choose another path, and --open to view the result in the
 default browser. With --open and no --output, the page is rendered to a
-temporary file so the source directory stays clean.`,
+temporary file so the source directory stays clean.
+
+Use "scripts markdown serve FILE" to preview a file while editing it: the
+page is served from localhost and reloads whenever the file changes.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		outputFlag, err := cmd.Flags().GetString("output")
@@ -68,26 +82,12 @@ temporary file so the source directory stays clean.`,
 			errors.HandleErrorWithReason(err, "Can't read the input file")
 		}
 
-		body := markdown.StripFrontmatter(src)
-
 		fallback := strings.TrimSuffix(filepath.Base(cfg.InputPath), filepath.Ext(cfg.InputPath))
-		title := markdown.ExtractTitle(body, fallback)
-
-		htmlBody, err := markdown.RenderMarkdown(body)
+		page, err := markdown.RenderPage(src, fallback)
 		if err != nil {
-			errors.HandleErrorWithReason(err, "Can't render the Markdown")
+			errors.HandleErrorWithReason(err, "Can't render the page")
 		}
 
-		chromaCSS, err := markdown.ChromaCSS()
-		if err != nil {
-			errors.HandleErrorWithReason(err, "Can't generate the syntax-highlighting CSS")
-		}
-
-		links := markdown.ExtractLinks(body)
-		logger.Debug("extracted links", "count", len(links))
-
-		page := markdown.BuildPage(htmlBody, title, chromaCSS, markdown.LinksFooter(links))
-
 		outPath := cfg.Output.Path
 		if cfg.Output.Temp {
 			f, err := os.CreateTemp("", cfg.Output.Path)
@@ -128,8 +128,303 @@ func openBrowser(path string) error {
 	return exec.Command(opener, path).Start()
 }
 
+var markdownServeCmd = &cobra.Command{
+	Use:   "serve [flags] <FILE>",
+	Short: "Preview a Markdown file in the browser, reloading it on every change",
+	Long: `Serves a live preview of a Markdown file on localhost. Every request
+renders the file afresh, and the page reloads itself whenever the file changes,
+keeping its scroll position. Other paths serve the files beside the source, so
+relative images and links work as they would on disk.
+
+The server listens on a random port of 127.0.0.1 unless --addr says otherwise,
+and prints its URL. It needs no network access, except for Mermaid diagrams,
+which load mermaid.js from a CDN unless --mermaid points at a local copy.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, err := cmd.Flags().GetString("addr")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --addr flag")
+		}
+
+		open, err := cmd.Flags().GetBool("open")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --open flag")
+		}
+
+		mermaidPath, err := cmd.Flags().GetString("mermaid")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --mermaid flag")
+		}
+		if mermaidPath == "" {
+			mermaidPath = viper.GetString("markdown.mermaid")
+		}
+
+		if err := checkLoopback(addr); err != nil {
+			errors.HandleError(err)
+		}
+
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't resolve the input path")
+		}
+		if _, err := os.Stat(path); err != nil {
+			errors.HandleErrorWithReason(err, "Can't read the input file")
+		}
+		if mermaidPath != "" {
+			if _, err := os.Stat(mermaidPath); err != nil {
+				errors.HandleErrorWithReason(err, "Can't read the local mermaid.js")
+			}
+		}
+
+		hub := newReloadHub()
+
+		watcher, err := watchSource(path, hub.broadcast)
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't watch the input file")
+		}
+		defer watcher.Close()
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't start the preview server")
+		}
+
+		server := &http.Server{Handler: previewHandler(path, mermaidPath, listener.Addr().String(), hub)}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			// Close rather than Shutdown: the event streams never go idle.
+			server.Close()
+		}()
+
+		url := "http://" + listener.Addr().String() + "/"
+		logger.Info("serving preview", "file", path, "url", url)
+		fmt.Println(url)
+
+		if open {
+			if err := openBrowser(url); err != nil {
+				errors.HandleErrorWithReason(err, "Can't open the browser")
+			}
+		}
+
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			errors.HandleErrorWithReason(err, "The preview server stopped")
+		}
+	},
+}
+
+// Paths the preview server reserves for itself; everything else is a file
+// beside the source.
+const (
+	previewEventsPath  = "/_live/events"
+	previewMermaidPath = "/_live/mermaid.min.js"
+)
+
+// reloadDelay is how long the watcher waits for a burst of file events, such
+// as an editor's write-then-rename save, to settle before reloading.
+const reloadDelay = 100 * time.Millisecond
+
+// checkLoopback rejects listen addresses other than localhost, since the
+// preview serves every file in the source's directory.
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid --addr %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("--addr %q is not a loopback address (the preview only listens on localhost)", addr)
+	}
+	return nil
+}
+
+// previewHandler serves the live page for path at "/", the reload events, a
+// local mermaid.js when mermaidPath is set, and the files beside path, except
+// dotfiles. It only answers requests addressed to addr, the address it
+// listens on, or to localhost on the same port: a page on another site that
+// points its own host name at 127.0.0.1 (DNS rebinding) is turned away.
+func previewHandler(path, mermaidPath, addr string, hub *reloadHub) http.Handler {
+	fallback := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	mermaidURL := ""
+	mux := http.NewServeMux()
+	mux.HandleFunc(previewEventsPath, hub.serveEvents)
+	if mermaidPath != "" {
+		mermaidURL = previewMermaidPath
+		mux.HandleFunc(previewMermaidPath, func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, mermaidPath)
+		})
+	}
+	files := http.FileServer(http.Dir(filepath.Dir(path)))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if hasDotSegment(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		page, status := renderPreview(path, fallback)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, markdown.LivePage(page, previewEventsPath, mermaidURL))
+	})
+
+	hosts := map[string]bool{addr: true}
+	if _, port, err := net.SplitHostPort(addr); err == nil {
+		for _, host := range []string{"localhost", "127.0.0.1", "::1"} {
+			hosts[net.JoinHostPort(host, port)] = true
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hosts[r.Host] {
+			http.Error(w, "unexpected Host header", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// hasDotSegment reports whether a URL path has a segment starting with a
+// dot, such as ".env" or ".git/config".
+func hasDotSegment(urlPath string) bool {
+	for _, segment := range strings.Split(urlPath, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// renderPreview renders path as a page. When the file cannot be read or
+// rendered, as while an editor replaces it, the page shows the error instead,
+// and still reloads once the file changes again.
+func renderPreview(path, fallback string) (string, int) {
+	src, err := os.ReadFile(path)
+	if err == nil {
+		var page string
+		if page, err = markdown.RenderPage(src, fallback); err == nil {
+			return page, http.StatusOK
+		}
+	}
+
+	logger.Error("rendering the preview", "file", path, "err", err)
+	body := "<h1>Can't render " + html.EscapeString(filepath.Base(path)) + "</h1>\n<pre>" + html.EscapeString(err.Error()) + "</pre>\n"
+	return markdown.BuildPage(body, fallback, "", ""), http.StatusInternalServerError
+}
+
+// watchSource calls onChange whenever the file at path is written, created,
+// renamed, or removed. It watches the file's directory rather than the file,
+// so that it keeps working when an editor saves by replacing the file.
+func watchSource(path string, onChange func()) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		var pending *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+					continue
+				}
+				logger.Debug("source changed", "event", event.String())
+				if pending != nil {
+					pending.Stop()
+				}
+				pending = time.AfterFunc(reloadDelay, onChange)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error("watching the source", "err", err)
+			}
+		}
+	}()
+
+	return watcher, nil
+}
+
+// reloadHub fans reload notifications out to every open event stream.
+type reloadHub struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{clients: map[chan struct{}]struct{}{}}
+}
+
+// broadcast tells every client to reload. A client that has yet to receive
+// the previous notification only reloads once.
+func (h *reloadHub) broadcast() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// serveEvents streams server-sent events to one page, a "reload" event per
+// change, until the page goes away.
+func (h *reloadHub) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	h.clients[ch] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, ch)
+		h.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// The browser reconnects this long after the stream drops, as when the
+	// server restarts.
+	fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			// Browsers drop events without data, so send some.
+			fmt.Fprint(w, "event: reload\ndata: changed\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(markdownCmd)
 	markdownCmd.Flags().StringP("output", "o", "", "Write HTML to this path instead of the default sibling path")
 	markdownCmd.Flags().Bool("open", false, "Open the result in the default browser (renders to a temporary file unless --output is set)")
+
+	markdownCmd.AddCommand(markdownServeCmd)
+	markdownServeCmd.Flags().String("addr", "127.0.0.1:0", "Listen on this loopback address (port 0 picks a free port)")
+	markdownServeCmd.Flags().Bool("open", false, "Open the preview in the default browser")
+	markdownServeCmd.Flags().String("mermaid", "", "Serve this local mermaid.min.js instead of loading it from the CDN (default: markdown.mermaid from the config)")
 }
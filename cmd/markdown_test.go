@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreviewHandler(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "doc.md")
+	files := map[string]string{
+		"doc.md":      "# Doc\n",
+		"pic.txt":     "picture\n",
+		".env":        "TOKEN=secret\n",
+		".git/config": "[core]\n",
+		"sub/.hidden": "hidden\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := previewHandler(path, "", "127.0.0.1:8123", newReloadHub())
+
+	tests := []struct {
+		name       string
+		host       string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "page", host: "127.0.0.1:8123", target: "/", wantStatus: http.StatusOK, wantBody: "<title>Doc</title>"},
+		{name: "localhost", host: "localhost:8123", target: "/", wantStatus: http.StatusOK},
+		{name: "IPv6 loopback", host: "[::1]:8123", target: "/", wantStatus: http.StatusOK},
+		{name: "file beside the source", host: "localhost:8123", target: "/pic.txt", wantStatus: http.StatusOK, wantBody: "picture"},
+		{name: "rebound host name", host: "attacker.example:8123", target: "/pic.txt", wantStatus: http.StatusForbidden},
+		{name: "rebound host name on the page", host: "attacker.example:8123", target: "/", wantStatus: http.StatusForbidden},
+		{name: "other port", host: "localhost:9999", target: "/", wantStatus: http.StatusForbidden},
+		{name: "dotfile", host: "localhost:8123", target: "/.env", wantStatus: http.StatusNotFound},
+		{name: "dot directory", host: "localhost:8123", target: "/.git/config", wantStatus: http.StatusNotFound},
+		{name: "nested dotfile", host: "localhost:8123", target: "/sub/.hidden", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+			if strings.Contains(rec.Body.String(), "secret") {
+				t.Errorf("body leaks a dotfile: %q", rec.Body.String())
+			}
+		})
+	}
+}
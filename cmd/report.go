@@ -22,13 +22,23 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cloudbridgeuy/scripts/pkg/errors"
 	"github.com/cloudbridgeuy/scripts/pkg/report"
 	"github.com/cloudbridgeuy/scripts/pkg/term"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var reportCmd = &cobra.Command{
@@ -39,7 +49,103 @@ command's description, exit code, and output. Input can come from stdin,
 a file, or inline arguments.
 
 Comments (# lines) become command descriptions. Line continuations (\)
-are supported. Output format is XML or Markdown.`,
+are supported. Directive comments configure the next command:
+
+  # @timeout 30s       kill the command after 30s
+  # @retries 3         run the command up to 3 more times until it passes
+  # @max-output-bytes 64KiB
+  #                     keep at most 64KiB of output (head and tail)
+  # @max-output-lines 200
+  #                     keep at most 200 lines of output (head and tail)
+  # @expect-exit 1     treat exit code 1 as success
+  # @cwd ./sub         run in another working directory
+  # @env FOO=bar       set an environment variable (repeatable)
+  # @allow-failure     never fail the run because of this command
+  # @tag smoke         label the command (repeatable); see --tag
+  # @expect-output-contains ready
+  #                     fail unless the output contains "ready"
+  # @expect-output-match ^v\d+\.
+  #                     fail unless the output matches the regexp
+  # @name build        name the command for @needs
+  # @needs build       run only after "build" passed; skipped otherwise
+
+Commands that others need run first, wherever they appear in the input;
+a command whose needs did not all pass is skipped. With --jobs,
+independent commands run concurrently. --tag also keeps the commands a
+selected command needs.
+
+With --from-markdown, commands come from the sh/bash code fences of a
+Markdown runbook instead: each fence is one command, described by the
+paragraph before it or the nearest heading. Fences tagged "norun" (e.g.
+"sh norun") are skipped. Add --annotate to print the runbook itself back,
+with each executed fence followed by a status badge and its output, instead
+of a report.
+
+Output format is XML, Markdown, JSON, JSON Lines (one object per command,
+streamed as each command finishes), JUnit XML, or a self-contained HTML page
+styled like "scripts markdown" output. --format template:PATH renders the
+report with a Go text/template from PATH instead; it receives every result.
+Every built-in format records each command's start and finish times, CPU
+time, peak memory, working directory, shell, and host, and ends with a
+summary of the total time and the slowest command.
+
+Use --jobs to run several commands concurrently; results are always reported
+in input order. Use --session to run every command, one at a time, in a
+single shell instead, so that cd, exported variables, and functions carry
+over from one command to the next; commands with @cwd or @env run in a
+subshell, and a command that exits the shell (or is killed) leaves the next
+one in a fresh shell. Use --timeout to kill commands (and their children) that run
+longer than the given duration. Use --retries to re-run commands that did
+not pass, waiting --retry-delay before the first retry and twice as long
+before each one after that (at most 30s); --on-error stop only stops once
+a command's retries are exhausted. With --strict, the exit status is 1 when
+any command fails, including failed output assertions.
+
+Use --host [user@]host (repeatable) to run the commands over ssh instead
+of locally, using your ssh configuration; ssh runs in batch mode, so it
+never prompts for a password. Hosts run one after another and the report
+combines them, grouped by host (JUnit gets one test suite per host); with
+--host-reports DIR, one report per host is written to DIR/HOST.EXT instead.
+The remote login shell must be a POSIX shell; --session runs locally only.
+
+Use --pick to choose the commands to run in fzf; the commands they need
+run too. Add --last REPORT.xml to show each command's result in a saved run.
+"scripts report rerun --failed REPORT.xml" runs only the failed commands of
+a saved report again and merges the fresh results into it.
+
+Commands can hold {{ .Var }} placeholders, filled from --var KEY=value,
+a YAML --vars file, or the environment, in that order of precedence. They
+are only filled when --var, --vars, or --fill is given, so Go template
+syntax meant for the command itself (docker --format) is left alone
+otherwise. A "# @matrix region=us,eu" directive runs its command once per
+value, with {{ .region }} set to it and the value appended to the
+description; several keys run every combination.
+
+Use --record NAME to keep the run in the history of the report NAME, under
+the user's configuration directory; "scripts report history NAME" lists its
+recorded runs and when each failing command started failing.
+
+Use --max-output-bytes and --max-output-lines to cap what is kept of each
+command's output while it runs: the first and last halves are kept, with a
+"[… truncated …]" line in between, and the report records the full size.
+
+Secrets are masked as [REDACTED] in commands, descriptions, and output
+before the report is written, and the report counts them: the values of
+environment variables named like GITHUB_PAT*, *_TOKEN, *_SECRET,
+*_PASSWORD, or *_API_KEY, and AWS keys, bearer tokens, GitHub and Slack
+tokens, and PEM private keys. Add variable name globs and regexps in
+~/.scripts.yaml (a regexp with a group masks only the group):
+
+  report:
+    redact:
+      env: [DEPLOY_KEY, "VAULT_*"]
+      patterns: ['password=(\S+)']
+
+Use --no-redact to turn masking off.
+
+On a terminal, a live view shows each command's progress, elapsed time, and
+latest output while the run is in progress; it is cleared before the report
+is printed. When stdout is not a terminal, nothing is printed until the end.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		formatStr, err := cmd.Flags().GetString("format")
 		if err != nil {
@@ -56,42 +162,772 @@ are supported. Output format is XML or Markdown.`,
 			errors.HandleErrorWithReason(err, "Can't get the --on-error flag")
 		}
 
+		jobs, err := cmd.Flags().GetInt("jobs")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --jobs flag")
+		}
+
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --timeout flag")
+		}
+
+		retries, err := cmd.Flags().GetInt("retries")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --retries flag")
+		}
+
+		retryDelay, err := cmd.Flags().GetDuration("retry-delay")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --retry-delay flag")
+		}
+
+		maxOutputBytesStr, err := cmd.Flags().GetString("max-output-bytes")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --max-output-bytes flag")
+		}
+
+		maxOutputLines, err := cmd.Flags().GetInt("max-output-lines")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --max-output-lines flag")
+		}
+
+		if retries < 0 {
+			errors.HandleError(fmt.Errorf("invalid --retries value: %d (expected 0 or more)", retries))
+		}
+
+		maxOutputBytes, err := report.ParseByteSize(maxOutputBytesStr)
+		if err != nil {
+			errors.HandleError(fmt.Errorf("invalid --max-output-bytes value: %w", err))
+		}
+
+		if maxOutputLines < 0 {
+			errors.HandleError(fmt.Errorf("invalid --max-output-lines value: %d (expected 0 or more)", maxOutputLines))
+		}
+
+		if jobs < 1 {
+			errors.HandleError(fmt.Errorf("invalid --jobs value: %d (expected 1 or more)", jobs))
+		}
+
 		format, err := report.ParseFormat(formatStr)
 		if err != nil {
 			errors.HandleError(err)
 		}
 
+		formatter, err := report.LookupFormatter(format)
+		if err != nil {
+			errors.HandleErrorWithReason(err, fmt.Sprintf("Can't load the %s format", formatStr))
+		}
+
 		onError, err := report.ParseOnErrorBehavior(onErrorStr)
 		if err != nil {
 			errors.HandleError(err)
 		}
 
-		input, err := report.ResolveInput(os.Stdin, fileFlag, args, term.IsInputTTY())
+		strict, err := cmd.Flags().GetBool("strict")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --strict flag")
+		}
+
+		session, err := cmd.Flags().GetBool("session")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --session flag")
+		}
+
+		tags, err := cmd.Flags().GetStringSlice("tag")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --tag flag")
+		}
+
+		runbookPath, err := cmd.Flags().GetString("from-markdown")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --from-markdown flag")
+		}
+
+		annotate, err := cmd.Flags().GetBool("annotate")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --annotate flag")
+		}
+
+		noRedact, err := cmd.Flags().GetBool("no-redact")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --no-redact flag")
+		}
+
+		hosts, err := cmd.Flags().GetStringSlice("host")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --host flag")
+		}
+
+		hostReports, err := cmd.Flags().GetString("host-reports")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --host-reports flag")
+		}
+
+		pick, err := cmd.Flags().GetBool("pick")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --pick flag")
+		}
+
+		lastPath, err := cmd.Flags().GetString("last")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --last flag")
+		}
+
+		record, err := cmd.Flags().GetString("record")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --record flag")
+		}
+
+		varFlags, err := cmd.Flags().GetStringArray("var")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --var flag")
+		}
+
+		varsFile, err := cmd.Flags().GetString("vars")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --vars flag")
+		}
+
+		fill, err := cmd.Flags().GetBool("fill")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --fill flag")
+		}
+
+		if annotate && runbookPath == "" {
+			errors.HandleError(fmt.Errorf("--annotate requires --from-markdown"))
+		}
+
+		if annotate && len(hosts) > 1 {
+			errors.HandleError(fmt.Errorf("--annotate takes at most one --host"))
+		}
+
+		if hostReports != "" && (len(hosts) == 0 || annotate) {
+			errors.HandleError(fmt.Errorf("--host-reports requires --host and cannot be used with --annotate"))
+		}
+
+		if lastPath != "" && !pick {
+			errors.HandleError(fmt.Errorf("--last requires --pick"))
+		}
+
+		if record != "" {
+			if err := report.ValidateReportName(record); err != nil {
+				errors.HandleError(err)
+			}
+		}
+
+		// Without --host, actions run with the local shell.
+		transports := []report.Transport{nil}
+		if len(hosts) > 0 {
+			transports = make([]report.Transport, len(hosts))
+			for i, host := range hosts {
+				transport, err := report.NewSSHTransport(host)
+				if err != nil {
+					errors.HandleError(err)
+				}
+				transports[i] = transport
+			}
+		}
+
+		var actions []report.Action
+		var runbook []byte
+		if runbookPath != "" {
+			runbook, err = os.ReadFile(runbookPath)
+			if err != nil {
+				errors.HandleErrorWithReason(err, "Can't read the Markdown runbook")
+			}
+
+			actions, err = report.ParseMarkdownActions(runbook)
+			if err != nil {
+				errors.HandleErrorWithReason(err, "Can't parse the Markdown runbook")
+			}
+		} else {
+			input, err := report.ResolveInput(os.Stdin, fileFlag, args, term.IsInputTTY())
+			if err != nil {
+				errors.HandleError(err)
+			}
+
+			actions, err = report.ParseActions(input)
+			if err != nil {
+				errors.HandleErrorWithReason(err, "Can't parse the report input")
+			}
+		}
+
+		// Variables come from the environment, then the vars file, then
+		// --var, each overriding the one before.
+		vars := report.EnvVars(os.Environ())
+		if varsFile != "" {
+			data, err := os.ReadFile(varsFile)
+			if err != nil {
+				errors.HandleErrorWithReason(err, "Can't read the --vars file")
+			}
+
+			fileVars, err := report.ParseVarsFile(data)
+			if err != nil {
+				errors.HandleErrorWithReason(err, fmt.Sprintf("Can't parse the --vars file %s", varsFile))
+			}
+			maps.Copy(vars, fileVars)
+		}
+		for _, kv := range varFlags {
+			key, value, err := report.ParseVar(kv)
+			if err != nil {
+				errors.HandleError(err)
+			}
+			vars[key] = value
+		}
+
+		actions, err = report.ExpandActions(actions, vars, fill || varsFile != "" || len(varFlags) > 0)
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't fill the report templates")
+		}
+
+		actions = report.FilterByTags(actions, tags)
+
+		if pick {
+			var last []report.Result
+			if lastPath != "" {
+				data, err := os.ReadFile(lastPath)
+				if err != nil {
+					errors.HandleErrorWithReason(err, "Can't read the --last report")
+				}
+
+				last, err = report.ParseXML(data)
+				if err != nil {
+					errors.HandleErrorWithReason(err, "Can't parse the --last report")
+				}
+			}
+
+			actions, err = pickActions(actions, last)
+			if err != nil {
+				errors.HandleError(err)
+			}
+		}
+
+		redactor := newRedactor(noRedact)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		cfg := report.ExecConfig{
+			Shell:      userShell(),
+			OnError:    onError,
+			Session:    session,
+			Jobs:       jobs,
+			Timeout:    timeout,
+			Retries:    retries,
+			RetryDelay: retryDelay,
+
+			MaxOutputBytes: maxOutputBytes,
+			MaxOutputLines: maxOutputLines,
+		}
+
+		// JSON Lines streams each result as soon as its action finishes.
+		streaming := format == report.JSONLines && !annotate && hostReports == ""
+		if streaming {
+			cfg.OnResult = func(index int, result report.Result) {
+				line, err := report.FormatJSONLine(index, redactor.RedactResult(result))
+				if err != nil {
+					errors.HandleError(err)
+				}
+				fmt.Println(line)
+			}
+		}
+
+		start := time.Now()
+
+		// Hosts run one after another; each run's results stay together, so
+		// the combined report is grouped by host.
+		runs := make([][]report.Result, 0, len(transports))
+		for _, transport := range transports {
+			cfg.Transport = transport
+
+			run := executeActions(ctx, actions, cfg, streaming)
+			runs = append(runs, redactor.RedactResults(run))
+
+			if ctx.Err() != nil || (onError == report.Stop && report.CountFailed(run) > 0) {
+				break
+			}
+		}
+
+		results := slices.Concat(runs...)
+
+		if record != "" {
+			path, err := report.RecordRun(reportHistoryDir(), record, start, results)
+			if err != nil {
+				errors.HandleErrorWithReason(err, "Can't record the run")
+			}
+			fmt.Fprintf(os.Stderr, "Recorded the run in %s\n", path)
+		}
+
+		if hostReports != "" {
+			if err := os.MkdirAll(hostReports, 0755); err != nil {
+				errors.HandleErrorWithReason(err, "Can't create the --host-reports directory")
+			}
+
+			for i, run := range runs {
+				output, err := formatter.Format(run)
+				if err != nil {
+					errors.HandleError(err)
+				}
+
+				path := hostReportPath(hostReports, hosts[i], format)
+				if err := os.WriteFile(path, []byte(output+"\n"), 0644); err != nil {
+					errors.HandleErrorWithReason(err, "Can't write the host report")
+				}
+				fmt.Println(path)
+			}
+		} else if annotate {
+			fmt.Print(report.AnnotateMarkdown(runbook, results, redactor))
+		} else if streaming {
+			summary, err := report.FormatJSONLinesSummary(results)
+			if err != nil {
+				errors.HandleError(err)
+			}
+			if summary != "" {
+				fmt.Println(summary)
+			}
+		} else {
+			output, err := formatter.Format(results)
+			if err != nil {
+				errors.HandleError(err)
+			}
+
+			fmt.Println(output)
+		}
+
+		if strict && report.CountFailed(results) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// newRedactor returns the redactor that masks secrets in report results, set
+// up from the report.redact settings, or nil with --no-redact. Secrets are
+// masked between execution and formatting; a nil redactor leaves results
+// untouched.
+func newRedactor(noRedact bool) *report.Redactor {
+	if noRedact {
+		return nil
+	}
+
+	secretEnv := append(slices.Clone(report.DefaultSecretEnv), viper.GetStringSlice("report.redact.env")...)
+	redactor, err := report.NewRedactor(os.Environ(), secretEnv, viper.GetStringSlice("report.redact.patterns"))
+	if err != nil {
+		errors.HandleErrorWithReason(err, "Can't read the report.redact settings")
+	}
+	return redactor
+}
+
+// userShell returns the shell report actions run with: $SHELL, or /bin/sh.
+func userShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+// reportHistoryDir returns the directory --record keeps runs in: the
+// report.history.dir setting, or scripts/reports under the user's
+// configuration directory.
+func reportHistoryDir() string {
+	if dir := viper.GetString("report.history.dir"); dir != "" {
+		return dir
+	}
+
+	config, err := os.UserConfigDir()
+	if err != nil {
+		errors.HandleErrorWithReason(err, "Can't find the user's configuration directory")
+	}
+	return filepath.Join(config, "scripts", "reports")
+}
+
+// executeActions runs actions with cfg. On a terminal, unless the report is
+// streamed to stdout while the actions run, it shows live progress; the view
+// is erased before the report is printed, so the report itself is the same as
+// when piped. The callbacks cfg already has still see every action.
+func executeActions(ctx context.Context, actions []report.Action, cfg report.ExecConfig, streamed bool) []report.Result {
+	if !term.IsOutputTTY() || streamed || len(actions) == 0 {
+		return report.ExecuteActions(ctx, actions, cfg)
+	}
+
+	width, height, _ := term.GetSize()
+	progress := report.NewProgress(os.Stdout, term.StdoutRenderer(), actions, width, height)
+
+	onStart, onOutput, onResult := cfg.OnStart, cfg.OnOutput, cfg.OnResult
+	cfg.OnStart = func(index int, action report.Action) {
+		progress.Start(index, action)
+		if onStart != nil {
+			onStart(index, action)
+		}
+	}
+	cfg.OnOutput = func(index int, chunk []byte) {
+		progress.Output(index, chunk)
+		if onOutput != nil {
+			onOutput(index, chunk)
+		}
+	}
+	cfg.OnResult = func(index int, result report.Result) {
+		progress.Finish(index, result)
+		if onResult != nil {
+			onResult(index, result)
+		}
+	}
+	go progress.Run()
+
+	results := report.ExecuteActions(ctx, actions, cfg)
+	progress.Stop()
+	return results
+}
+
+// pickActions lets the user choose actions with fzf, previewing each one's
+// command and its result in last, and returns the chosen actions together
+// with the actions they need.
+func pickActions(actions []report.Action, last []report.Result) ([]report.Action, error) {
+	previous := report.LastResults(actions, last)
+
+	// fzf previews an item by printing its file.
+	dir, err := os.MkdirTemp("", "scripts-report-pick-")
+	if err != nil {
+		return nil, fmt.Errorf("creating the preview directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, action := range actions {
+		preview := report.PickPreview(action, previous[i])
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.txt", i)), []byte(preview), 0600); err != nil {
+			return nil, fmt.Errorf("writing the preview of command %d: %w", i+1, err)
+		}
+	}
+
+	fzf := exec.Command(
+		"fzf",
+		"--multi",
+		"--delimiter", "\t",
+		"--with-nth", "2..",
+		"--prompt", "report> ",
+		"--header", "TAB to select, ENTER to run",
+		"--preview", fmt.Sprintf("cat '%s'/{1}.txt", dir),
+		"--preview-window", "right:60%:wrap",
+	)
+	fzf.Stdin = strings.NewReader(strings.Join(report.PickItems(actions, previous), "\n") + "\n")
+	fzf.Stderr = os.Stderr
+
+	output, err := fzf.Output()
+	if err != nil {
+		// fzf exits with 1 when nothing matched and 130 when cancelled.
+		if exitErr, ok := err.(*exec.ExitError); ok && (exitErr.ExitCode() == 1 || exitErr.ExitCode() == 130) {
+			return nil, fmt.Errorf("no commands picked")
+		}
+		return nil, fmt.Errorf("running fzf: %w", err)
+	}
+
+	indices, err := report.ParsePicked(string(output), len(actions))
+	if err != nil {
+		return nil, err
+	}
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("no commands picked")
+	}
+
+	return report.SelectActions(actions, indices), nil
+}
+
+// hostReportPath returns the path of the --host-reports file for host: the
+// host with path separators and colons replaced, and the format's extension.
+func hostReportPath(dir, host string, format report.Format) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(host)
+	return filepath.Join(dir, name+format.Extension())
+}
+
+var reportDiffCmd = &cobra.Command{
+	Use:   "diff OLD.xml NEW.xml",
+	Short: "Compare two saved XML reports and highlight regressions",
+	Long: `Reads two reports saved with --format xml, matches their commands by
+description and command, and prints a Markdown summary of what changed: exit
+code changes and a unified diff of each command's output.
+
+Commands are matched by description and command together, then by command
+alone, then by description alone. The exit status is 1 when any command that
+passed in OLD fails in NEW.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runs := make([][]report.Result, len(args))
+		for i, path := range args {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				errors.HandleErrorWithReason(err, "Can't read the report")
+			}
+
+			runs[i], err = report.ParseXML(data)
+			if err != nil {
+				errors.HandleErrorWithReason(err, fmt.Sprintf("Can't parse the report %s", path))
+			}
+		}
+
+		diffs := report.DiffResults(runs[0], runs[1])
+		fmt.Print(report.FormatDiff(diffs))
+
+		if report.CountRegressed(diffs) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var reportRerunCmd = &cobra.Command{
+	Use:   "rerun [flags] REPORT.xml",
+	Short: "Re-run the commands of a saved XML report and merge in the fresh results",
+	Long: `Reads a report saved with --format xml, runs its commands again, and
+prints the report with each re-run command's result replaced by the fresh
+one. With --failed, only the commands that failed the run, or were skipped
+because a command they need failed, run again; the rest keep their saved
+results.
+
+Commands run in the directory they ran in before, and commands that ran on a
+remote --host run on that host again. Options the XML does not record, such
+as @env and output limits, are not applied. Commands whose saved text was
+redacted cannot run again and keep their saved results.
+
+The merged report is printed as XML, or in another --format; with
+--in-place, it is written back to REPORT.xml instead. With --strict, the
+exit status is 1 when any command of the merged report fails.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failedOnly, err := cmd.Flags().GetBool("failed")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --failed flag")
+		}
+
+		formatStr, err := cmd.Flags().GetString("format")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --format flag")
+		}
+
+		inPlace, err := cmd.Flags().GetBool("in-place")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --in-place flag")
+		}
+
+		jobs, err := cmd.Flags().GetInt("jobs")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --jobs flag")
+		}
+
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --timeout flag")
+		}
+
+		retries, err := cmd.Flags().GetInt("retries")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --retries flag")
+		}
+
+		noRedact, err := cmd.Flags().GetBool("no-redact")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --no-redact flag")
+		}
+
+		strict, err := cmd.Flags().GetBool("strict")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --strict flag")
+		}
+
+		if jobs < 1 {
+			errors.HandleError(fmt.Errorf("invalid --jobs value: %d (expected 1 or more)", jobs))
+		}
+
+		if retries < 0 {
+			errors.HandleError(fmt.Errorf("invalid --retries value: %d (expected 0 or more)", retries))
+		}
+
+		format, err := report.ParseFormat(formatStr)
 		if err != nil {
 			errors.HandleError(err)
 		}
 
-		actions := report.ParseActions(input)
+		formatter, err := report.LookupFormatter(format)
+		if err != nil {
+			errors.HandleErrorWithReason(err, fmt.Sprintf("Can't load the %s format", formatStr))
+		}
+
+		if inPlace && cmd.Flags().Changed("format") && format != report.XML {
+			errors.HandleError(fmt.Errorf("--in-place writes XML and cannot be used with --format %s", formatStr))
+		}
+
+		path := args[0]
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't read the report")
+		}
+
+		saved, err := report.ParseXML(data)
+		if err != nil {
+			errors.HandleErrorWithReason(err, fmt.Sprintf("Can't parse the report %s", path))
+		}
+
+		groups, redacted := report.PlanRerun(saved, failedOnly)
+		for _, i := range redacted {
+			fmt.Fprintf(os.Stderr, "Keeping the saved result of command %d: its command was redacted\n", i+1)
+		}
+
+		redactor := newRedactor(noRedact)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// Each group runs on the machine its commands ran on before.
+		fresh := map[int]report.Result{}
+		for _, group := range groups {
+			cfg := report.ExecConfig{
+				Shell:      userShell(),
+				Jobs:       jobs,
+				Timeout:    timeout,
+				Retries:    retries,
+				RetryDelay: time.Second,
+			}
+			if group.Host != "" {
+				transport, err := report.NewSSHTransport(group.Host)
+				if err != nil {
+					errors.HandleError(err)
+				}
+				cfg.Transport = transport
+			}
+
+			actions := make([]report.Action, len(group.Indices))
+			for k, i := range group.Indices {
+				actions[k] = report.RerunAction(saved[i])
+			}
+
+			// Actions that never started, as when the run is interrupted,
+			// record nothing and keep their saved result.
+			cfg.OnResult = group.Record(fresh)
+			executeActions(ctx, actions, cfg, false)
+
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		for i, r := range fresh {
+			fresh[i] = redactor.RedactResult(r)
+		}
+		results := report.MergeResults(saved, fresh)
+
+		if inPlace {
+			output, err := report.FormatXML(results)
+			if err != nil {
+				errors.HandleError(err)
+			}
 
-		shell := os.Getenv("SHELL")
-		if shell == "" {
-			shell = "/bin/sh"
+			if err := os.WriteFile(path, []byte(output+"\n"), 0644); err != nil {
+				errors.HandleErrorWithReason(err, "Can't write the report")
+			}
+			fmt.Println(path)
+		} else {
+			output, err := formatter.Format(results)
+			if err != nil {
+				errors.HandleError(err)
+			}
+
+			fmt.Println(output)
 		}
 
-		results := report.ExecuteActions(actions, onError, shell)
+		if strict && report.CountFailed(results) > 0 {
+			os.Exit(1)
+		}
+	},
+}
 
-		output, err := report.FormatReport(results, format)
+var reportHistoryCmd = &cobra.Command{
+	Use:   "history [NAME]",
+	Short: "Show the recorded runs of a report and when its commands started failing",
+	Long: `Lists the runs of the report NAME recorded with --record NAME, newest
+first, with their start time, pass, fail, and skip counts, and duration. It
+then lists each command of the latest run that failed in any recorded run,
+with its latest status, the run its current streak of failures started in,
+the first run it failed in, and how many runs it failed in.
+
+Runs are kept under the report.history.dir setting, or scripts/reports in
+the user's configuration directory (~/.config on Linux); each run is an XML
+report that "scripts report diff" and "scripts report rerun" can read.
+
+Without NAME, lists the reports that have recorded runs.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			errors.HandleErrorWithReason(err, "Can't get the --limit flag")
+		}
+
+		if limit < 0 {
+			errors.HandleError(fmt.Errorf("invalid --limit value: %d (expected 0 or more)", limit))
+		}
+
+		dir := reportHistoryDir()
+
+		if len(args) == 0 {
+			names, err := report.ListHistories(dir)
+			if err != nil {
+				errors.HandleErrorWithReason(err, "Can't list the recorded reports")
+			}
+			for _, name := range names {
+				fmt.Println(name)
+			}
+			return
+		}
+
+		runs, err := report.LoadHistory(dir, args[0])
 		if err != nil {
 			errors.HandleError(err)
 		}
 
-		fmt.Println(output)
+		if limit > 0 && len(runs) > limit {
+			runs = runs[len(runs)-limit:]
+		}
+
+		fmt.Print(report.FormatHistory(args[0], runs))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(reportCmd)
-	reportCmd.Flags().StringP("format", "f", "md", "Output format: xml or md")
+	reportCmd.AddCommand(reportDiffCmd)
+	reportCmd.AddCommand(reportRerunCmd)
+	reportCmd.AddCommand(reportHistoryCmd)
+	reportCmd.Flags().StringP("format", "f", "md", "Output format: xml, md, json, jsonl, junit, html, or template:PATH")
 	reportCmd.Flags().String("file", "", "Read commands from file")
+	reportCmd.Flags().String("from-markdown", "", "Run the shell code fences of a Markdown runbook")
+	reportCmd.MarkFlagsMutuallyExclusive("file", "from-markdown")
+	reportCmd.Flags().Bool("annotate", false, "With --from-markdown, print the runbook annotated with each command's status and output")
+	reportCmd.Flags().Bool("no-redact", false, "Keep secrets in commands and output instead of masking them")
 	reportCmd.Flags().String("on-error", "continue", "Error behavior: continue or stop")
+	reportCmd.Flags().IntP("jobs", "j", 1, "Maximum number of commands to run concurrently")
+	reportCmd.Flags().Bool("session", false, "Run every command, one at a time, in a single shared shell")
+	reportCmd.Flags().StringSlice("host", nil, "Run the commands on this [user@]host over ssh instead of locally (repeatable)")
+	reportCmd.Flags().String("host-reports", "", "With --host, write one report per host into this directory instead of printing a combined report")
+	reportCmd.MarkFlagsMutuallyExclusive("session", "host")
+	reportCmd.Flags().Bool("strict", false, "Exit with status 1 when any command or output assertion fails")
+	reportCmd.Flags().StringSlice("tag", nil, "Only run commands carrying one of these tags (repeatable)")
+	reportCmd.Flags().Duration("timeout", 0, "Kill each command after this duration (e.g. 30s); 0 disables")
+	reportCmd.Flags().Int("retries", 0, "Re-run a command that did not pass up to this many times")
+	reportCmd.Flags().Duration("retry-delay", time.Second, "Wait before the first retry; doubles for each retry after it")
+	reportCmd.Flags().String("max-output-bytes", "0", "Keep at most this much of each command's output (e.g. 64KiB), its head and tail; 0 disables")
+	reportCmd.Flags().Int("max-output-lines", 0, "Keep at most this many lines of each command's output, its head and tail; 0 disables")
+	reportCmd.Flags().Bool("pick", false, "Choose the commands to run with fzf")
+	reportCmd.Flags().String("last", "", "With --pick, preview each command's result in this saved XML report")
+	reportCmd.Flags().String("record", "", "Record the run in the history of the report with this name")
+	reportCmd.Flags().StringArray("var", nil, "Set a template variable as KEY=value (repeatable)")
+	reportCmd.Flags().String("vars", "", "Read template variables from this YAML file")
+	reportCmd.Flags().Bool("fill", false, "Fill {{ .Var }} placeholders even without --var or --vars, from the environment")
+
+	reportRerunCmd.Flags().Bool("failed", false, "Only re-run the commands that failed or were skipped")
+	reportRerunCmd.Flags().StringP("format", "f", "xml", "Output format: xml, md, json, jsonl, junit, html, or template:PATH")
+	reportRerunCmd.Flags().Bool("in-place", false, "Write the merged report back to REPORT.xml instead of printing it")
+	reportRerunCmd.Flags().IntP("jobs", "j", 1, "Maximum number of commands to run concurrently")
+	reportRerunCmd.Flags().Duration("timeout", 0, "Kill commands without a saved @timeout after this duration (e.g. 30s); 0 disables")
+	reportRerunCmd.Flags().Int("retries", 0, "Re-run a command without saved @retries that did not pass up to this many times")
+	reportRerunCmd.Flags().Bool("no-redact", false, "Keep secrets in commands and output instead of masking them")
+	reportRerunCmd.Flags().Bool("strict", false, "Exit with status 1 when any command of the merged report fails")
+
+	reportHistoryCmd.Flags().Int("limit", 0, "Only consider the latest this many runs; 0 shows every run")
 }
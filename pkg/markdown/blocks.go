@@ -0,0 +1,150 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// CodeBlock is one fenced code block found in a Markdown document, with the
+// context a reader would use to tell what it is for.
+type CodeBlock struct {
+	// Language is the first word of the info string ("sh" in "```sh norun").
+	Language string
+	// Attrs holds the remaining words of the info string ("norun").
+	Attrs []string
+	// Code is the block's contents, verbatim.
+	Code string
+	// Heading is the text of the nearest heading above the block.
+	Heading string
+	// Paragraph is the text of the paragraph directly before the block, with
+	// its lines joined by spaces, or "" when the block does not follow one.
+	Paragraph string
+	// Line is the 1-based line of the opening fence, or 0 when unknown (an
+	// empty fence without an info string).
+	Line int
+	// End is the byte offset just past the closing fence line (or past the
+	// last line of an unterminated fence), where content can be inserted
+	// after the block.
+	End int
+}
+
+// ExtractCodeBlocks parses src with the same GFM parser used for rendering
+// and returns every fenced code block in document order.
+func ExtractCodeBlocks(src []byte) []CodeBlock {
+	parser := goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser()
+	root := parser.Parse(text.NewReader(src))
+
+	var blocks []CodeBlock
+	var heading string
+
+	_ = ast.Walk(root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Heading:
+			heading = strings.TrimSpace(nodeText(n, src))
+		case *ast.FencedCodeBlock:
+			blocks = append(blocks, newCodeBlock(n, src, heading))
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return blocks
+}
+
+// newCodeBlock builds a CodeBlock from a fenced code block node.
+func newCodeBlock(n *ast.FencedCodeBlock, src []byte, heading string) CodeBlock {
+	var code bytes.Buffer
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		code.Write(seg.Value(src))
+	}
+
+	block := CodeBlock{
+		Code:    code.String(),
+		Heading: heading,
+	}
+
+	if n.Info != nil {
+		fields := strings.Fields(string(n.Info.Segment.Value(src)))
+		if len(fields) > 0 {
+			block.Language = fields[0]
+			block.Attrs = fields[1:]
+		}
+		block.Line = lineOf(src, n.Info.Segment.Start)
+	} else if n.Lines().Len() > 0 {
+		block.Line = lineOf(src, n.Lines().At(0).Start) - 1
+	}
+
+	block.End = fenceEnd(n, src)
+
+	if p, ok := n.PreviousSibling().(*ast.Paragraph); ok {
+		block.Paragraph = paragraphText(p, src)
+	}
+
+	return block
+}
+
+// fenceEnd returns the byte offset just past the block's closing fence line.
+// goldmark records the content lines but not the fences, so the closing fence
+// is found as the line right after the content (or after the info string for
+// an empty block).
+func fenceEnd(n *ast.FencedCodeBlock, src []byte) int {
+	var pos int
+	switch {
+	case n.Lines().Len() > 0:
+		pos = lineStart(src, n.Lines().At(n.Lines().Len()-1).Stop)
+	case n.Info != nil:
+		pos = lineStart(src, n.Info.Segment.Stop)
+	default:
+		return 0
+	}
+
+	next := nextLine(src, pos)
+	fence := strings.TrimSpace(string(src[pos:next]))
+	if len(fence) >= 3 && (fence[0] == '`' || fence[0] == '~') && strings.Trim(fence, fence[:1]) == "" {
+		return next
+	}
+	return pos
+}
+
+// lineStart returns offset when it already sits at the start of a line, and
+// otherwise the start of the following line.
+func lineStart(src []byte, offset int) int {
+	if offset == 0 || offset > len(src) || src[offset-1] == '\n' {
+		return min(offset, len(src))
+	}
+	return nextLine(src, offset)
+}
+
+// nextLine returns the offset just past the newline that ends the line
+// containing offset, or len(src) on the last line.
+func nextLine(src []byte, offset int) int {
+	i := bytes.IndexByte(src[offset:], '\n')
+	if i < 0 {
+		return len(src)
+	}
+	return offset + i + 1
+}
+
+// paragraphText joins a paragraph's source lines with spaces.
+func paragraphText(p *ast.Paragraph, src []byte) string {
+	lines := make([]string, p.Lines().Len())
+	for i := range lines {
+		seg := p.Lines().At(i)
+		lines[i] = strings.TrimSpace(string(seg.Value(src)))
+	}
+	return strings.Join(lines, " ")
+}
+
+// lineOf returns the 1-based line containing byte offset in src.
+func lineOf(src []byte, offset int) int {
+	return bytes.Count(src[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,73 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractCodeBlocks(t *testing.T) {
+	src := []byte("# Deploy\n" +
+		"\n" +
+		"## Check pods\n" +
+		"\n" +
+		"List every pod in\n" +
+		"the namespace:\n" +
+		"\n" +
+		"```sh\n" +
+		"kubectl get pods\n" +
+		"```\n" +
+		"\n" +
+		"```bash norun\n" +
+		"rm -rf /\n" +
+		"```\n" +
+		"\n" +
+		"- item\n" +
+		"\n" +
+		"~~~\n" +
+		"plain\n" +
+		"~~~\n")
+
+	blocks := ExtractCodeBlocks(src)
+
+	want := []CodeBlock{
+		{Language: "sh", Attrs: []string{}, Code: "kubectl get pods\n", Heading: "Check pods", Paragraph: "List every pod in the namespace:", Line: 8, End: 86},
+		{Language: "bash", Attrs: []string{"norun"}, Code: "rm -rf /\n", Heading: "Check pods", Line: 12, End: 114},
+		{Code: "plain\n", Heading: "Check pods", Line: 18, End: 137},
+	}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Errorf("ExtractCodeBlocks() =\n%#v\nwant\n%#v", blocks, want)
+	}
+}
+
+func TestExtractCodeBlocksEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // the source up to End
+	}{
+		{name: "closed fence", src: "```sh\nls\n```\nafter\n", want: "```sh\nls\n```\n"},
+		{name: "closed fence at end of file", src: "```sh\nls\n```", want: "```sh\nls\n```"},
+		{name: "empty fence", src: "```sh\n```\nafter\n", want: "```sh\n```\n"},
+		{name: "longer closing fence", src: "~~~sh\nls\n~~~~~\nafter\n", want: "~~~sh\nls\n~~~~~\n"},
+		{name: "unterminated fence", src: "```sh\nls\n", want: "```sh\nls\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := ExtractCodeBlocks([]byte(tt.src))
+			if len(blocks) != 1 {
+				t.Fatalf("expected 1 block, got %d", len(blocks))
+			}
+			if got := tt.src[:blocks[0].End]; got != tt.want {
+				t.Errorf("source up to End = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractCodeBlocksNone(t *testing.T) {
+	blocks := ExtractCodeBlocks([]byte("# Title\n\nJust `inline` code.\n\n    indented code\n"))
+	if len(blocks) != 0 {
+		t.Errorf("expected no fenced blocks, got %#v", blocks)
+	}
+}
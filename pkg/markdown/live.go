@@ -0,0 +1,30 @@
+package markdown
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// MermaidCDN is where mermaid.html loads mermaid.js from.
+const MermaidCDN = "https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.min.js"
+
+//go:embed live.js
+var liveScript string
+
+// LivePage prepares a page built by BuildPage for `scripts markdown serve`:
+// it adds a script that listens for "reload" events on eventsURL and reloads
+// the page, keeping its scroll position. A non-empty mermaidURL replaces
+// MermaidCDN, so that diagrams render without network access.
+func LivePage(page, eventsURL, mermaidURL string) string {
+	if mermaidURL != "" {
+		page = strings.Replace(page, `src="`+MermaidCDN+`"`, `src="`+mermaidURL+`"`, 1)
+	}
+
+	script := "<script>\n" + strings.ReplaceAll(liveScript, "{{EVENTS_URL}}", eventsURL) + "</script>\n"
+
+	i := strings.LastIndex(page, "</body>")
+	if i < 0 {
+		return page + script
+	}
+	return page[:i] + script + page[i:]
+}
@@ -0,0 +1,22 @@
+// Live reload for `scripts markdown serve`. The server sends a "reload"
+// event whenever the source changes; the page remembers where it was
+// scrolled to, reloads, and scrolls back once it has loaded again.
+(function () {
+  var key = 'scripts-markdown-scroll:' + location.pathname;
+
+  window.addEventListener('load', function () {
+    var saved = sessionStorage.getItem(key);
+    if (saved === null) return;
+    sessionStorage.removeItem(key);
+    var y = parseInt(saved, 10);
+    window.scrollTo(0, y);
+    // Mermaid renders its diagrams after load, which can move the page.
+    setTimeout(function () { window.scrollTo(0, y); }, 250);
+  });
+
+  var events = new EventSource('{{EVENTS_URL}}');
+  events.addEventListener('reload', function () {
+    sessionStorage.setItem(key, String(window.scrollY));
+    location.reload();
+  });
+})();
@@ -0,0 +1,58 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLivePage(t *testing.T) {
+	page := BuildPage("<p>hello</p>", "T", "", "")
+
+	tests := []struct {
+		name       string
+		mermaidURL string
+		verify     func(t *testing.T, got string)
+	}{
+		{
+			name: "reload script before the end of the body",
+			verify: func(t *testing.T, got string) {
+				script := strings.Index(got, "new EventSource('/_live/events')")
+				if script < 0 {
+					t.Fatalf("no event source for the events URL:\n%s", got)
+				}
+				if end := strings.LastIndex(got, "</body>"); end < script {
+					t.Errorf("reload script is not inside the body:\n%s", got)
+				}
+				if strings.Contains(got, "{{EVENTS_URL}}") {
+					t.Errorf("unreplaced placeholder remains:\n%s", got)
+				}
+			},
+		},
+		{
+			name: "keeps the CDN without a local mermaid",
+			verify: func(t *testing.T, got string) {
+				if !strings.Contains(got, `src="`+MermaidCDN+`"`) {
+					t.Errorf("mermaid CDN script missing:\n%s", got)
+				}
+			},
+		},
+		{
+			name:       "local mermaid replaces the CDN",
+			mermaidURL: "/_live/mermaid.min.js",
+			verify: func(t *testing.T, got string) {
+				if strings.Contains(got, MermaidCDN) {
+					t.Errorf("page still loads mermaid from the CDN:\n%s", got)
+				}
+				if !strings.Contains(got, `<script src="/_live/mermaid.min.js"></script>`) {
+					t.Errorf("local mermaid script missing:\n%s", got)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.verify(t, LivePage(page, "/_live/events", tt.mermaidURL))
+		})
+	}
+}
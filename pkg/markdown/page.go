@@ -23,6 +23,7 @@ package markdown
 
 import (
 	_ "embed"
+	"fmt"
 	"html"
 	"strings"
 )
@@ -33,15 +34,51 @@ var pageTemplate string
 //go:embed styles.css
 var pageCSS string
 
+//go:embed mermaid.html
+var mermaidScripts string
+
 // BuildPage assembles a complete HTML document from a rendered body fragment,
 // a page title, a generated chroma stylesheet, and an optional links footer
-// (pass "" for documents without external links).
+// (pass "" for documents without external links). The page loads mermaid.js
+// to draw the body's diagrams.
 func BuildPage(body, title, chromaCSS, linksHTML string) string {
+	return buildPage(body, title, chromaCSS, linksHTML, mermaidScripts)
+}
+
+// BuildStaticPage assembles a page like BuildPage, but without any script,
+// for bodies that hold no Mermaid diagrams. The page needs nothing but itself
+// to be viewed.
+func BuildStaticPage(body, title, chromaCSS, linksHTML string) string {
+	return buildPage(body, title, chromaCSS, linksHTML, "")
+}
+
+func buildPage(body, title, chromaCSS, linksHTML, scripts string) string {
 	return strings.NewReplacer(
 		"{{TITLE}}", html.EscapeString(title),
 		"{{PAGE_CSS}}", pageCSS,
 		"{{CHROMA_CSS}}", chromaCSS,
+		"{{SCRIPTS}}", scripts,
 		"{{BODY}}", body,
 		"{{LINKS}}", linksHTML,
 	).Replace(pageTemplate)
 }
+
+// RenderPage runs the whole pipeline over a Markdown source: it strips the
+// front matter, takes the title from the first H1 (or fallback), renders the
+// body, and wraps it with the chroma stylesheet and links footer.
+func RenderPage(src []byte, fallback string) (string, error) {
+	body := StripFrontmatter(src)
+	title := ExtractTitle(body, fallback)
+
+	htmlBody, err := RenderMarkdown(body)
+	if err != nil {
+		return "", fmt.Errorf("rendering the Markdown: %w", err)
+	}
+
+	chromaCSS, err := ChromaCSS()
+	if err != nil {
+		return "", fmt.Errorf("generating the syntax-highlighting CSS: %w", err)
+	}
+
+	return BuildPage(htmlBody, title, chromaCSS, LinksFooter(ExtractLinks(body))), nil
+}
@@ -68,3 +68,47 @@ func TestBuildPageInjectsLinksFooter(t *testing.T) {
 		t.Errorf("empty links footer should leave no footer element:\n%s", empty)
 	}
 }
+
+func TestBuildStaticPage(t *testing.T) {
+	page := BuildStaticPage("<p>hello</p>", "T", ".chroma { color: #fff; }", "")
+
+	if !strings.Contains(page, "<p>hello</p>") || !strings.Contains(page, ".chroma { color: #fff; }") {
+		t.Errorf("body or chroma CSS not injected:\n%s", page)
+	}
+	if strings.Contains(page, "<script") {
+		t.Errorf("static page carries a script:\n%s", page)
+	}
+	if strings.Contains(page, "{{") {
+		t.Errorf("unreplaced placeholder remains:\n%s", page)
+	}
+}
+
+func TestRenderPage(t *testing.T) {
+	src := []byte("---\ntitle: ignored\n---\n# Hello\n\nSee [docs](https://example.com).\n\n```go\nfunc main() {}\n```\n")
+
+	page, err := RenderPage(src, "fallback")
+	if err != nil {
+		t.Fatalf("RenderPage() error = %v", err)
+	}
+
+	if !strings.Contains(page, "<title>Hello</title>") {
+		t.Errorf("title not taken from the first heading:\n%s", page)
+	}
+	if strings.Contains(page, "title: ignored") {
+		t.Errorf("front matter not stripped:\n%s", page)
+	}
+	if !strings.Contains(page, `<footer class="links">`) {
+		t.Errorf("links footer missing:\n%s", page)
+	}
+	if !strings.Contains(page, `class="chroma"`) {
+		t.Errorf("code fence not highlighted:\n%s", page)
+	}
+
+	untitled, err := RenderPage([]byte("no heading\n"), "fallback")
+	if err != nil {
+		t.Fatalf("RenderPage() error = %v", err)
+	}
+	if !strings.Contains(untitled, "<title>fallback</title>") {
+		t.Errorf("fallback title not used:\n%s", untitled)
+	}
+}
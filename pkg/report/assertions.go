@@ -0,0 +1,116 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// AssertionKind names how an Assertion checks an action's output.
+type AssertionKind string
+
+const (
+	// OutputContains passes when the output contains Pattern verbatim.
+	OutputContains AssertionKind = "output-contains"
+	// OutputMatches passes when the regular expression Pattern matches the output.
+	OutputMatches AssertionKind = "output-match"
+)
+
+// Assertion is an expectation about an action's combined output, declared
+// with "# @expect-output-contains" or "# @expect-output-match".
+type Assertion struct {
+	Kind    AssertionKind
+	Pattern string
+}
+
+// String renders the assertion as it reads in a report, e.g.
+// `output-contains "ready"`.
+func (a Assertion) String() string {
+	return fmt.Sprintf("%s %q", a.Kind, a.Pattern)
+}
+
+// AssertionResult records whether an Assertion held for one run.
+type AssertionResult struct {
+	Assertion Assertion
+	Passed    bool
+}
+
+// newAssertion validates pattern for kind; regular expressions are compiled
+// so that mistakes surface at parse time rather than after the command ran.
+func newAssertion(kind AssertionKind, pattern string) (Assertion, error) {
+	if kind == OutputMatches {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return Assertion{}, err
+		}
+	}
+	return Assertion{Kind: kind, Pattern: pattern}, nil
+}
+
+// regexp returns a regular expression that matches where the assertion
+// holds.
+func (a Assertion) regexp() (*regexp.Regexp, error) {
+	switch a.Kind {
+	case OutputContains:
+		return regexp.Compile(regexp.QuoteMeta(a.Pattern))
+	case OutputMatches:
+		return regexp.Compile(a.Pattern)
+	default:
+		return nil, fmt.Errorf("unknown assertion kind %q", a.Kind)
+	}
+}
+
+// outputMatcher checks an assertion against a command's output as it is
+// written, so the assertion sees every byte even when the capture keeps only
+// the beginning and end of the output. The pattern runs over the stream in a
+// goroutine, which holds no more than a read buffer of it at a time.
+type outputMatcher struct {
+	assertion Assertion
+	w         *io.PipeWriter
+	done      chan bool
+	result    *AssertionResult
+}
+
+func newOutputMatcher(a Assertion) *outputMatcher {
+	r, w := io.Pipe()
+	m := &outputMatcher{assertion: a, w: w, done: make(chan bool, 1)}
+
+	go func() {
+		re, err := a.regexp()
+		matched := err == nil && re.MatchReader(bufio.NewReader(r))
+		// Keep reading once the pattern matched, so writes never block.
+		_, _ = io.Copy(io.Discard, r)
+		m.done <- matched
+	}()
+
+	return m
+}
+
+// Write feeds p to the pattern. Writes after the result was taken are
+// ignored.
+func (m *outputMatcher) Write(p []byte) (int, error) {
+	if m.result == nil {
+		_, _ = m.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// Result ends the output and returns whether the assertion held for it.
+func (m *outputMatcher) Result() AssertionResult {
+	if m.result == nil {
+		m.w.Close()
+		m.result = &AssertionResult{Assertion: m.assertion, Passed: <-m.done}
+	}
+	return *m.result
+}
+
+// FailedAssertions returns the assertions of r that did not hold.
+func (r Result) FailedAssertions() []Assertion {
+	var failed []Assertion
+	for _, ar := range r.Assertions {
+		if !ar.Passed {
+			failed = append(failed, ar.Assertion)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"testing"
+)
+
+func TestOutputMatcher(t *testing.T) {
+	output := "server v1.4.2 ready\n"
+
+	tests := []struct {
+		name      string
+		assertion Assertion
+		want      bool
+	}{
+		{name: "contains match", assertion: Assertion{Kind: OutputContains, Pattern: "ready"}, want: true},
+		{name: "contains miss", assertion: Assertion{Kind: OutputContains, Pattern: "stopped"}, want: false},
+		{name: "regexp match", assertion: Assertion{Kind: OutputMatches, Pattern: `v\d+\.\d+`}, want: true},
+		{name: "anchored regexp miss", assertion: Assertion{Kind: OutputMatches, Pattern: `^v\d+\.`}, want: false},
+		{name: "multi-line regexp", assertion: Assertion{Kind: OutputMatches, Pattern: `(?m)ready$`}, want: true},
+		{name: "invalid regexp never passes", assertion: Assertion{Kind: OutputMatches, Pattern: `(`}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newOutputCapture(0, 0, []Assertion{tt.assertion}, nil)
+			c.Stdout().Write([]byte(output))
+			got := c.AssertionResults()
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			if got[0].Passed != tt.want {
+				t.Errorf("Passed = %v, want %v", got[0].Passed, tt.want)
+			}
+			if got[0].Assertion != tt.assertion {
+				t.Errorf("Assertion = %+v, want %+v", got[0].Assertion, tt.assertion)
+			}
+		})
+	}
+
+	t.Run("no assertions", func(t *testing.T) {
+		c := newOutputCapture(0, 0, nil, nil)
+		c.Stdout().Write([]byte(output))
+		if got := c.AssertionResults(); got != nil {
+			t.Errorf("got %+v, want nil", got)
+		}
+	})
+}
+
+func TestResultAssertions(t *testing.T) {
+	ready := Assertion{Kind: OutputContains, Pattern: "ready"}
+	version := Assertion{Kind: OutputMatches, Pattern: `^v\d`}
+
+	r := Result{
+		Action: Action{Command: "status", Assertions: []Assertion{ready, version}},
+		Assertions: []AssertionResult{
+			{Assertion: ready, Passed: true},
+			{Assertion: version, Passed: false},
+		},
+	}
+
+	failed := r.FailedAssertions()
+	if len(failed) != 1 || failed[0] != version {
+		t.Errorf("FailedAssertions() = %+v, want [%+v]", failed, version)
+	}
+	if r.Passed() {
+		t.Error("a result with a failed assertion must not pass")
+	}
+	if !r.Failed() {
+		t.Error("a result with a failed assertion must fail the run")
+	}
+	if CountFailed([]Result{r, {Action: Action{Command: "true"}}}) != 1 {
+		t.Error("CountFailed should count only the failing result")
+	}
+
+	if got := version.String(); got != `output-match "^v\\d"` {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,245 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+)
+
+// outputCapture records a command's stdout and stderr separately while also
+// keeping a combined view that preserves the order in which writes arrived.
+// Each view is capped as set by newOutputCapture, so a noisy command cannot
+// make the capture grow without bound. Assertions are checked against the
+// whole combined output as it streams past, before any of it is dropped.
+// onWrite, when set, sees every write as it arrives, under the capture lock.
+type outputCapture struct {
+	mu       sync.Mutex
+	stdout   cappedBuffer
+	stderr   cappedBuffer
+	combined cappedBuffer
+	matchers []*outputMatcher
+	onWrite  func(p []byte)
+}
+
+// newOutputCapture returns a capture whose views each keep at most maxBytes
+// bytes and maxLines lines; zero means no limit.
+func newOutputCapture(maxBytes, maxLines int, assertions []Assertion, onWrite func(p []byte)) *outputCapture {
+	c := &outputCapture{onWrite: onWrite}
+	for _, b := range []*cappedBuffer{&c.stdout, &c.stderr, &c.combined} {
+		b.maxBytes, b.maxLines = maxBytes, maxLines
+	}
+	for _, a := range assertions {
+		c.matchers = append(c.matchers, newOutputMatcher(a))
+	}
+	return c
+}
+
+// AssertionResults ends the output and returns whether each assertion held
+// for it, in order; nil when there are no assertions.
+func (c *outputCapture) AssertionResults() []AssertionResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.matchers) == 0 {
+		return nil
+	}
+	results := make([]AssertionResult, len(c.matchers))
+	for i, m := range c.matchers {
+		results[i] = m.Result()
+	}
+	return results
+}
+
+// streamWriter is the io.Writer handed to exec.Cmd for one stream.
+type streamWriter struct {
+	capture *outputCapture
+	stream  *cappedBuffer
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w.capture.mu.Lock()
+	defer w.capture.mu.Unlock()
+
+	w.stream.Write(p)
+	w.capture.combined.Write(p)
+	for _, m := range w.capture.matchers {
+		m.Write(p)
+	}
+	if w.capture.onWrite != nil {
+		w.capture.onWrite(p)
+	}
+	return len(p), nil
+}
+
+// Stdout returns the writer for the command's standard output.
+func (c *outputCapture) Stdout() streamWriter {
+	return streamWriter{capture: c, stream: &c.stdout}
+}
+
+// Stderr returns the writer for the command's standard error.
+func (c *outputCapture) Stderr() streamWriter {
+	return streamWriter{capture: c, stream: &c.stderr}
+}
+
+// cappedBuffer keeps the beginning and the end of a stream within maxBytes
+// and maxLines, each split evenly between head and tail, and counts what it
+// drops from the middle. Zero limits keep everything.
+type cappedBuffer struct {
+	maxBytes int
+	maxLines int
+
+	head       []byte
+	headBreaks int
+	headFull   bool
+	tail       []byte
+	tailBreaks int
+
+	size   int
+	breaks int
+	last   byte
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n := len(p)
+	b.size += n
+	b.breaks += bytes.Count(p, []byte{'\n'})
+	b.last = p[n-1]
+
+	if !b.headFull {
+		room := b.headRoom(p)
+		b.head = append(b.head, p[:room]...)
+		b.headBreaks += bytes.Count(p[:room], []byte{'\n'})
+		p = p[room:]
+		if len(p) == 0 {
+			return n, nil
+		}
+		b.headFull = true
+	}
+
+	b.tail = append(b.tail, p...)
+	b.tailBreaks += bytes.Count(p, []byte{'\n'})
+	b.trimTail()
+
+	return n, nil
+}
+
+// headRoom returns how much of p still fits in the head.
+func (b *cappedBuffer) headRoom(p []byte) int {
+	room := len(p)
+
+	if b.maxBytes > 0 {
+		room = min(room, max(0, b.maxBytes/2-len(b.head)))
+		// Do not split a UTF-8 sequence between head and tail.
+		for room > 0 && room < len(p) && !utf8.RuneStart(p[room]) {
+			room--
+		}
+	}
+
+	if b.maxLines > 0 {
+		lines := b.maxLines/2 - b.headBreaks
+		cut, k := 0, 0
+		for k < lines {
+			i := bytes.IndexByte(p[cut:room], '\n')
+			if i < 0 {
+				break
+			}
+			cut += i + 1
+			k++
+		}
+		if k == lines {
+			room = cut
+		}
+	}
+
+	return room
+}
+
+// trimTail drops the oldest tail bytes beyond the tail's share of the limits.
+func (b *cappedBuffer) trimTail() {
+	if b.maxBytes > 0 {
+		if drop := len(b.tail) - (b.maxBytes - b.maxBytes/2); drop > 0 {
+			for drop < len(b.tail) && !utf8.RuneStart(b.tail[drop]) {
+				drop++
+			}
+			b.tailBreaks -= bytes.Count(b.tail[:drop], []byte{'\n'})
+			b.tail = b.tail[drop:]
+		}
+	}
+
+	if b.maxLines > 0 {
+		for lineCount(b.tail, b.tailBreaks) > b.maxLines-b.maxLines/2 {
+			i := bytes.IndexByte(b.tail, '\n')
+			b.tail = b.tail[i+1:]
+			b.tailBreaks--
+		}
+	}
+}
+
+// lineCount counts the lines of text, which holds breaks newlines, including
+// an unterminated last line.
+func lineCount(text []byte, breaks int) int {
+	if len(text) > 0 && text[len(text)-1] != '\n' {
+		return breaks + 1
+	}
+	return breaks
+}
+
+// Truncated reports whether anything was dropped.
+func (b *cappedBuffer) Truncated() bool {
+	return b.size > len(b.head)+len(b.tail)
+}
+
+// Size returns the number of bytes written.
+func (b *cappedBuffer) Size() int {
+	return b.size
+}
+
+// Lines returns the number of lines written, counting an unterminated last
+// line.
+func (b *cappedBuffer) Lines() int {
+	if b.size > 0 && b.last != '\n' {
+		return b.breaks + 1
+	}
+	return b.breaks
+}
+
+// String returns the kept output. When something was dropped, a marker line
+// between the head and the tail says how much.
+func (b *cappedBuffer) String() string {
+	if !b.Truncated() {
+		return string(b.head) + string(b.tail)
+	}
+
+	var s bytes.Buffer
+	s.Write(b.head)
+	if len(b.head) > 0 && b.head[len(b.head)-1] != '\n' {
+		s.WriteByte('\n')
+	}
+
+	dropped := b.size - len(b.head) - len(b.tail)
+	droppedLines := b.breaks - b.headBreaks - b.tailBreaks
+	fmt.Fprintf(&s, "[… truncated %s, %d lines …]\n", formatBytes(dropped), droppedLines)
+
+	s.Write(b.tail)
+	return s.String()
+}
+
+// formatBytes renders n in the largest binary unit that keeps it at or above
+// one, e.g. "512 B", "1.5 KiB", or "12.0 MiB".
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[prefix])
+}
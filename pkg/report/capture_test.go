@@ -0,0 +1,202 @@
+package report
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		maxLines int
+		writes   []string
+		want     string
+	}{
+		{
+			name:   "no limits keep everything",
+			writes: []string{"one\n", "two\n"},
+			want:   "one\ntwo\n",
+		},
+		{
+			name:     "under the limits",
+			maxBytes: 100,
+			maxLines: 10,
+			writes:   []string{"one\n", "two\n"},
+			want:     "one\ntwo\n",
+		},
+		{
+			name:     "byte limit keeps head and tail",
+			maxBytes: 8,
+			writes:   []string{"abcdefgh", "ijklmnop"},
+			want:     "abcd\n[… truncated 8 B, 0 lines …]\nmnop",
+		},
+		{
+			name:     "line limit keeps head and tail",
+			maxLines: 4,
+			writes:   []string{"1\n2\n3\n", "4\n5\n6\n7\n"},
+			want:     "1\n2\n[… truncated 6 B, 3 lines …]\n6\n7\n",
+		},
+		{
+			name:     "odd line limit favors the tail",
+			maxLines: 3,
+			writes:   []string{"1\n2\n3\n4\n5\n"},
+			want:     "1\n[… truncated 4 B, 2 lines …]\n4\n5\n",
+		},
+		{
+			name:     "multi-byte runes are not split",
+			maxBytes: 5,
+			writes:   []string{"ééééé"},
+			want:     "é\n[… truncated 6 B, 0 lines …]\né",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := cappedBuffer{maxBytes: tt.maxBytes, maxLines: tt.maxLines}
+			size := 0
+			for _, w := range tt.writes {
+				b.Write([]byte(w))
+				size += len(w)
+			}
+
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if b.Size() != size {
+				t.Errorf("Size() = %d, want %d", b.Size(), size)
+			}
+			if truncated := tt.want != strings.Join(tt.writes, ""); b.Truncated() != truncated {
+				t.Errorf("Truncated() = %v, want %v", b.Truncated(), truncated)
+			}
+		})
+	}
+}
+
+func TestCappedBufferLines(t *testing.T) {
+	var b cappedBuffer
+	b.Write([]byte("a\nb"))
+	if b.Lines() != 2 {
+		t.Errorf("Lines() = %d, want 2 with an unterminated last line", b.Lines())
+	}
+	b.Write([]byte("\n"))
+	if b.Lines() != 2 {
+		t.Errorf("Lines() = %d, want 2", b.Lines())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int]string{0: "0 B", 512: "512 B", 1536: "1.5 KiB", 12 << 20: "12.0 MiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for input, want := range map[string]int{"0": 0, "512": 512, "64KiB": 64 << 10, "64k": 64 << 10, "10 MB": 10 << 20, "1GiB": 1 << 30} {
+		got, err := ParseByteSize(input)
+		if err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"", "lots", "-1", "10TB", "1.5MB"} {
+		if _, err := ParseByteSize(input); err == nil {
+			t.Errorf("ParseByteSize(%q) returned no error", input)
+		}
+	}
+}
+
+func TestExecuteActionsOutputLimits(t *testing.T) {
+	actions := []Action{
+		{Command: "seq 1 1000"},
+		{Command: "seq 1 1000", MaxOutputLines: 4},
+		{Command: "echo short"},
+	}
+
+	got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", MaxOutputLines: 10})
+
+	if want := "1\n2\n3\n4\n5\n[… truncated 3.8 KiB, 990 lines …]\n996\n997\n998\n999\n1000\n"; got[0].Output != want {
+		t.Errorf("result[0].Output = %q, want %q", got[0].Output, want)
+	}
+	if !got[0].Truncated || got[0].OutputSize != 3893 || got[0].OutputLines != 1000 {
+		t.Errorf("result[0] = truncated %v, size %d, lines %d; want true, 3893, 1000", got[0].Truncated, got[0].OutputSize, got[0].OutputLines)
+	}
+	if got[0].Stdout != got[0].Output {
+		t.Errorf("result[0].Stdout = %q, want it capped like the output", got[0].Stdout)
+	}
+
+	if want := "1\n2\n[… truncated 3.8 KiB, 996 lines …]\n999\n1000\n"; got[1].Output != want {
+		t.Errorf("result[1].Output = %q, want the action's own limit", got[1].Output)
+	}
+
+	if got[2].Truncated || got[2].OutputSize != 0 || got[2].Output != "short\n" {
+		t.Errorf("result[2] = %+v, want untruncated output", got[2])
+	}
+}
+
+func TestOutputCaptureAssertions(t *testing.T) {
+	assertions := []Assertion{
+		{Kind: OutputContains, Pattern: "ready now"},
+		{Kind: OutputMatches, Pattern: `(?m)^step 5$`},
+		{Kind: OutputMatches, Pattern: `truncated`},
+		{Kind: OutputContains, Pattern: "stopped"},
+	}
+
+	c := newOutputCapture(0, 4, assertions, nil)
+
+	// The pattern arrives split across writes, in output the capture drops.
+	writes := []string{"step 1\nstep 2\n", "ready ", "now\n", "step 4\nstep 5\n", "step 6\nstep 7\nstep 8\n"}
+	for i, w := range writes {
+		stream := c.Stdout()
+		if i%2 == 1 {
+			stream = c.Stderr()
+		}
+		stream.Write([]byte(w))
+	}
+
+	if !c.combined.Truncated() {
+		t.Fatalf("combined output = %q, want it truncated", c.combined.String())
+	}
+
+	got := c.AssertionResults()
+	want := []bool{true, true, false, false}
+	for i, r := range got {
+		if r.Assertion != assertions[i] || r.Passed != want[i] {
+			t.Errorf("result[%d] = %+v, want %v for %+v", i, r, want[i], assertions[i])
+		}
+	}
+
+	// Late writes, as from a process outliving its command, change nothing.
+	c.Stdout().Write([]byte("stopped\n"))
+	if got := c.AssertionResults(); got[3].Passed {
+		t.Errorf("late write changed the result: %+v", got[3])
+	}
+}
+
+func TestExecuteActionsAssertionsTruncated(t *testing.T) {
+	action := Action{
+		Command:        "seq 1 100 | sed 's/^/line-/'",
+		MaxOutputLines: 4,
+		Assertions: []Assertion{
+			{Kind: OutputContains, Pattern: "line-50"},
+			{Kind: OutputMatches, Pattern: `truncated`},
+		},
+	}
+
+	for _, session := range []bool{false, true} {
+		got := ExecuteActions(context.Background(), []Action{action}, ExecConfig{Shell: "/bin/sh", Session: session})
+
+		if !got[0].Truncated {
+			t.Fatalf("session %v: output = %q, want it truncated", session, got[0].Output)
+		}
+		if !got[0].Assertions[0].Passed {
+			t.Errorf("session %v: %+v failed, want it to see the dropped output", session, got[0].Assertions[0])
+		}
+		if got[0].Assertions[1].Passed {
+			t.Errorf("session %v: %+v passed, want it not to match the truncation marker", session, got[0].Assertions[1])
+		}
+	}
+}
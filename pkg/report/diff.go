@@ -0,0 +1,175 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Change classifies how an action differs between two report runs.
+type Change string
+
+const (
+	Unchanged Change = "unchanged"
+	Changed   Change = "changed"
+	Regressed Change = "regressed"
+	Fixed     Change = "fixed"
+	Added     Change = "added"
+	Removed   Change = "removed"
+)
+
+// changes lists every Change, in the order FormatDiff summarizes them.
+var changes = []Change{Regressed, Fixed, Changed, Added, Removed, Unchanged}
+
+// ActionDiff pairs an action's results from an old and a new run. Old is nil
+// for an action only in the new run, and New for one only in the old run.
+type ActionDiff struct {
+	Old *Result
+	New *Result
+}
+
+// Change classifies the pair. An action regressed when it passed before and
+// now fails the run, and was fixed in the opposite case; it changed when its
+// exit code or output differs without flipping between the two.
+func (d ActionDiff) Change() Change {
+	switch {
+	case d.Old == nil:
+		return Added
+	case d.New == nil:
+		return Removed
+	case d.Old.Passed() && d.New.Failed():
+		return Regressed
+	case d.Old.Failed() && d.New.Passed():
+		return Fixed
+	case d.Old.ExitCode != d.New.ExitCode || d.Old.Output != d.New.Output:
+		return Changed
+	default:
+		return Unchanged
+	}
+}
+
+// DiffResults matches the actions of a run before and after a change and
+// returns one ActionDiff per action, in the order of the after run followed by
+// actions only in the before run.
+//
+// Actions are matched, in this order of preference, by description and
+// command together, then by command alone, then by a non-empty description
+// alone; repeated actions pair up in the order they ran.
+func DiffResults(before, after []Result) []ActionDiff {
+	pairs := make([]int, len(after))
+	for i := range pairs {
+		pairs[i] = -1
+	}
+	used := make([]bool, len(before))
+
+	keys := []func(r Result) string{
+		func(r Result) string { return r.Action.Description + "\x00" + r.Action.Command },
+		func(r Result) string { return r.Action.Command },
+		func(r Result) string { return r.Action.Description },
+	}
+
+	for _, key := range keys {
+		unmatched := map[string][]int{}
+		for i, r := range before {
+			if !used[i] {
+				k := key(r)
+				unmatched[k] = append(unmatched[k], i)
+			}
+		}
+
+		for i, r := range after {
+			k := key(r)
+			if pairs[i] >= 0 || k == "" || len(unmatched[k]) == 0 {
+				continue
+			}
+			j := unmatched[k][0]
+			unmatched[k] = unmatched[k][1:]
+			pairs[i] = j
+			used[j] = true
+		}
+	}
+
+	diffs := make([]ActionDiff, 0, len(after))
+	for i := range after {
+		d := ActionDiff{New: &after[i]}
+		if j := pairs[i]; j >= 0 {
+			d.Old = &before[j]
+		}
+		diffs = append(diffs, d)
+	}
+	for j := range before {
+		if !used[j] {
+			diffs = append(diffs, ActionDiff{Old: &before[j]})
+		}
+	}
+
+	return diffs
+}
+
+// CountRegressed returns the number of regressed actions in diffs.
+func CountRegressed(diffs []ActionDiff) int {
+	n := 0
+	for _, d := range diffs {
+		if d.Change() == Regressed {
+			n++
+		}
+	}
+	return n
+}
+
+// FormatDiff renders diffs as a Markdown document: a summary line with the
+// count of each kind of change, then a section per action that did not stay
+// the same, showing its exit code change and a unified diff of its output.
+func FormatDiff(diffs []ActionDiff) string {
+	var b strings.Builder
+
+	b.WriteString("# Report Diff\n")
+
+	counts := map[Change]int{}
+	for _, d := range diffs {
+		counts[d.Change()]++
+	}
+	var summary []string
+	for _, c := range changes {
+		if counts[c] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[c], c))
+		}
+	}
+	if len(summary) == 0 {
+		summary = append(summary, "no actions")
+	}
+	fmt.Fprintf(&b, "\n%s\n", strings.Join(summary, " · "))
+
+	for _, d := range diffs {
+		change := d.Change()
+		if change == Unchanged {
+			continue
+		}
+
+		r := d.New
+		if r == nil {
+			r = d.Old
+		}
+		fmt.Fprintf(&b, "\n## %s: %s\n", strings.ToUpper(string(change[:1]))+string(change[1:]), actionLabel(r.Action))
+
+		fence := codeFence(r.Action.Command)
+		fmt.Fprintf(&b, "\n%s\n%s\n%s\n", fence, r.Action.Command, fence)
+
+		if d.Old == nil || d.New == nil {
+			fmt.Fprintf(&b, "\n**Exit Code**: %d\n", r.ExitCode)
+			continue
+		}
+
+		if d.Old.ExitCode != d.New.ExitCode {
+			fmt.Fprintf(&b, "\n**Exit Code**: %d → %d\n", d.Old.ExitCode, d.New.ExitCode)
+		} else {
+			fmt.Fprintf(&b, "\n**Exit Code**: %d\n", r.ExitCode)
+		}
+
+		if diff := unifiedDiff("old", "new", d.Old.Output, d.New.Output); diff != "" {
+			fence := codeFence(diff)
+			fmt.Fprintf(&b, "\n%sdiff\n%s%s\n", fence, diff, fence)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	before := []Result{
+		{Action: Action{Description: "Ping", Command: "ping -c1 db"}},
+		{Action: Action{Description: "Disk", Command: "df -h"}, Output: "10%\n"},
+		{Action: Action{Command: "curl localhost"}, ExitCode: 7},
+		{Action: Action{Description: "Version", Command: "app --version"}, Output: "1.0\n"},
+		{Action: Action{Command: "echo gone"}},
+	}
+	after := []Result{
+		{Action: Action{Description: "Version", Command: "app version"}, Output: "1.1\n"},
+		{Action: Action{Description: "Ping", Command: "ping -c1 db"}, ExitCode: 1},
+		{Action: Action{Description: "Disk usage", Command: "df -h"}, Output: "10%\n"},
+		{Action: Action{Command: "curl localhost"}},
+		{Action: Action{Command: "echo new"}},
+	}
+
+	diffs := DiffResults(before, after)
+
+	want := []struct {
+		change Change
+		old    string
+		new    string
+	}{
+		{Changed, "app --version", "app version"},
+		{Regressed, "ping -c1 db", "ping -c1 db"},
+		{Unchanged, "df -h", "df -h"},
+		{Fixed, "curl localhost", "curl localhost"},
+		{Added, "", "echo new"},
+		{Removed, "echo gone", ""},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d diffs, want %d", len(diffs), len(want))
+	}
+	for i, w := range want {
+		d := diffs[i]
+		if d.Change() != w.change {
+			t.Errorf("diffs[%d].Change() = %s, want %s", i, d.Change(), w.change)
+		}
+		if got := commandOf(d.Old); got != w.old {
+			t.Errorf("diffs[%d].Old = %q, want %q", i, got, w.old)
+		}
+		if got := commandOf(d.New); got != w.new {
+			t.Errorf("diffs[%d].New = %q, want %q", i, got, w.new)
+		}
+	}
+
+	if n := CountRegressed(diffs); n != 1 {
+		t.Errorf("CountRegressed() = %d, want 1", n)
+	}
+}
+
+func TestDiffResultsRepeatedActions(t *testing.T) {
+	before := []Result{
+		{Action: Action{Command: "date"}, Output: "Mon\n"},
+		{Action: Action{Command: "date"}, Output: "Tue\n"},
+	}
+	after := []Result{
+		{Action: Action{Command: "date"}, Output: "Mon\n"},
+		{Action: Action{Command: "date"}, Output: "Wed\n"},
+	}
+
+	diffs := DiffResults(before, after)
+	if len(diffs) != 2 || diffs[0].Change() != Unchanged || diffs[1].Change() != Changed {
+		t.Errorf("repeated actions should pair up in order, got %v and %v", diffs[0].Change(), diffs[1].Change())
+	}
+}
+
+func TestDiffResultsAllowedFailureIsNotRegression(t *testing.T) {
+	before := []Result{{Action: Action{Command: "flaky", AllowFailure: true}}}
+	after := []Result{{Action: Action{Command: "flaky", AllowFailure: true}, ExitCode: 1}}
+
+	if c := DiffResults(before, after)[0].Change(); c != Changed {
+		t.Errorf("Change() = %s, want %s", c, Changed)
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	before := []Result{
+		{Action: Action{Description: "Health", Command: "curl /health"}, Output: "status: ok\nversion: 1\n"},
+		{Action: Action{Command: "true"}},
+	}
+	after := []Result{
+		{Action: Action{Description: "Health", Command: "curl /health"}, ExitCode: 22, Output: "status: down\nversion: 1\n"},
+		{Action: Action{Command: "true"}},
+	}
+
+	got := FormatDiff(DiffResults(before, after))
+
+	for _, want := range []string{
+		"# Report Diff\n\n1 regressed · 1 unchanged\n",
+		"## Regressed: Health\n\n```\ncurl /health\n```\n",
+		"**Exit Code**: 0 → 22\n",
+		"```diff\n--- old\n+++ new\n@@ -1,2 +1,2 @@\n-status: ok\n+status: down\n version: 1\n```\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "## Unchanged") {
+		t.Error("unchanged actions should not get a section")
+	}
+}
+
+// commandOf returns r's command, or "" for a nil result.
+func commandOf(r *Result) string {
+	if r == nil {
+		return ""
+	}
+	return r.Action.Command
+}
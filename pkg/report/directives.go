@@ -0,0 +1,180 @@
+package report
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directive names recognised in "# @name [argument]" comment lines.
+const (
+	directiveTimeout      = "timeout"
+	directiveRetries      = "retries"
+	directiveMaxBytes     = "max-output-bytes"
+	directiveMaxLines     = "max-output-lines"
+	directiveExpectExit   = "expect-exit"
+	directiveCwd          = "cwd"
+	directiveEnv          = "env"
+	directiveAllowFailure = "allow-failure"
+	directiveTag          = "tag"
+	directiveName         = "name"
+	directiveNeeds        = "needs"
+	directiveMatrix       = "matrix"
+
+	directiveExpectOutputContains = "expect-output-contains"
+	directiveExpectOutputMatch    = "expect-output-match"
+)
+
+// isDirective reports whether the text of a comment line (without the
+// leading "#") is a directive.
+func isDirective(comment string) bool {
+	return strings.HasPrefix(comment, "@")
+}
+
+// applyDirective parses a single directive comment such as "@timeout 30s" and
+// records it on action. line is the 1-based input line, used in errors.
+func applyDirective(action *Action, comment string, line int) error {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(comment, "@"), " ")
+	arg = strings.TrimSpace(arg)
+
+	requireArg := func() error {
+		if arg == "" {
+			return fmt.Errorf("line %d: directive @%s requires an argument", line, name)
+		}
+		return nil
+	}
+
+	switch name {
+	case directiveTimeout:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("line %d: invalid @timeout %q (expected a positive duration such as 30s)", line, arg)
+		}
+		action.Timeout = d
+
+	case directiveRetries:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return fmt.Errorf("line %d: invalid @retries %q (expected a positive integer)", line, arg)
+		}
+		action.Retries = n
+
+	case directiveMaxBytes:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		n, err := ParseByteSize(arg)
+		if err != nil || n < 1 {
+			return fmt.Errorf("line %d: invalid @max-output-bytes %q (expected a positive size such as 64KiB)", line, arg)
+		}
+		action.MaxOutputBytes = n
+
+	case directiveMaxLines:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return fmt.Errorf("line %d: invalid @max-output-lines %q (expected a positive integer)", line, arg)
+		}
+		action.MaxOutputLines = n
+
+	case directiveExpectExit:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		code, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid @expect-exit %q (expected an integer)", line, arg)
+		}
+		action.ExpectExit = code
+
+	case directiveCwd:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		action.Dir = arg
+
+	case directiveEnv:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		key, _, ok := strings.Cut(arg, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("line %d: invalid @env %q (expected KEY=value)", line, arg)
+		}
+		action.Env = append(action.Env, arg)
+
+	case directiveAllowFailure:
+		if arg != "" {
+			return fmt.Errorf("line %d: directive @%s takes no argument", line, name)
+		}
+		action.AllowFailure = true
+
+	case directiveTag:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		action.Tags = append(action.Tags, strings.Fields(arg)...)
+
+	case directiveName:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		if len(strings.Fields(arg)) != 1 {
+			return fmt.Errorf("line %d: invalid @name %q (expected a single word)", line, arg)
+		}
+		action.Name = arg
+
+	case directiveNeeds:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		action.Needs = append(action.Needs, strings.Fields(arg)...)
+
+	case directiveMatrix:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		for _, field := range strings.Fields(arg) {
+			key, list, _ := strings.Cut(field, "=")
+			values := strings.Split(list, ",")
+			if !varName.MatchString(key) || slices.Contains(values, "") {
+				return fmt.Errorf("line %d: invalid @matrix %q (expected KEY=value,value)", line, field)
+			}
+			if slices.ContainsFunc(action.Matrix, func(a MatrixAxis) bool { return a.Key == key }) {
+				return fmt.Errorf("line %d: duplicate @matrix key %q", line, key)
+			}
+			action.Matrix = append(action.Matrix, MatrixAxis{Key: key, Values: values})
+		}
+
+	case directiveExpectOutputContains:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		action.Assertions = append(action.Assertions, Assertion{Kind: OutputContains, Pattern: arg})
+
+	case directiveExpectOutputMatch:
+		if err := requireArg(); err != nil {
+			return err
+		}
+		assertion, err := newAssertion(OutputMatches, arg)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid @expect-output-match %q: %w", line, arg, err)
+		}
+		action.Assertions = append(action.Assertions, assertion)
+
+	default:
+		return fmt.Errorf("line %d: unknown directive %q", line, "@"+name)
+	}
+
+	return nil
+}
@@ -1,14 +1,26 @@
 package report
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
+// waitDelay bounds how long Wait keeps reading output after a killed action,
+// in case a descendant escaped the process group and still holds the pipe.
+const waitDelay = 2 * time.Second
+
+// maxRetryDelay caps the exponential backoff between retries.
+const maxRetryDelay = 30 * time.Second
+
 // ResolveInput determines the input text from the available sources.
 // Priority: filePath > piped stdin > args > error.
 func ResolveInput(stdin io.Reader, filePath string, args []string, isInputTTY bool) (string, error) {
@@ -48,26 +60,344 @@ func exitCode(err error) int {
 	return 1
 }
 
-// ExecuteActions runs each action using the given shell and collects results.
-// If onError is Stop and a command fails, execution halts and partial results are returned.
-func ExecuteActions(actions []Action, onError OnErrorBehavior, shell string) []Result {
-	results := make([]Result, 0, len(actions))
+// checkDir reports a missing or non-directory working directory up front;
+// exec would otherwise blame the shell binary for the failed chdir.
+func checkDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("working directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("working directory: %s is not a directory", dir)
+	}
+	return nil
+}
 
-	for _, action := range actions {
-		cmd := exec.Command(shell, "-c", action.Command)
-		output, err := cmd.CombinedOutput()
-		code := exitCode(err)
+// runAction executes a single action through cfg's transport, with the given
+// shell when local, and returns its result. The action is killed, together
+// with its process group, when ctx is done or its timeout elapses. Output is
+// forwarded to onOutput, if set, as it arrives.
+func runAction(ctx context.Context, action Action, cfg ExecConfig, onOutput func([]byte)) Result {
+	if timeout := cfg.EffectiveTimeout(action); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	maxBytes, maxLines := cfg.EffectiveOutputLimits(action)
+	capture := newOutputCapture(maxBytes, maxLines, action.Assertions, onOutput)
+	transport := cfg.transport()
+
+	start := time.Now()
+	cmd, err := transport.Command(ctx, cfg.Shell, action)
+	if err == nil {
+		setProcessGroup(cmd)
+		cmd.WaitDelay = waitDelay
+		cmd.Stdout = capture.Stdout()
+		cmd.Stderr = capture.Stderr()
+		err = cmd.Run()
+	}
+	end := time.Now()
 
-		results = append(results, Result{
-			Action:   action,
-			ExitCode: code,
-			Output:   string(output),
+	// Errors that are not an exit status (a missing working directory, an
+	// unknown shell) would otherwise leave the result without any output.
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		fmt.Fprintln(capture.Stderr(), err)
+	}
+
+	timedOut := err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
+	result := newResult(action, capture, exitCode(err), timedOut, start, end)
+
+	// The process of a remote action is the local client; its resource usage
+	// says nothing about the command, and relative directories are resolved
+	// by the remote shell.
+	if transport.Host() != "" {
+		result.Dir = action.Dir
+		return result
+	}
+
+	result.Dir = workDir("", action.Dir)
+	if cmd != nil && cmd.ProcessState != nil {
+		state := cmd.ProcessState
+		result.UserTime, result.SystemTime, result.MaxRSS = state.UserTime(), state.SystemTime(), maxRSS(state)
+	}
+	return result
+}
+
+// workDir returns the absolute directory a command starts in when it is run
+// with dir as its working directory from base; an empty base stands for the
+// current directory of this process.
+func workDir(base, dir string) string {
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	if base == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return dir
+		}
+		base = wd
+	}
+	return filepath.Join(base, dir)
+}
+
+// newResult assembles the result of one run of action from its captured
+// output, with the action's assertions checked against the whole combined
+// output, including anything the capture dropped.
+func newResult(action Action, capture *outputCapture, code int, timedOut bool, start, end time.Time) Result {
+	output := capture.combined.String()
+
+	var size, lines int
+	truncated := capture.combined.Truncated() || capture.stdout.Truncated() || capture.stderr.Truncated()
+	if truncated {
+		size, lines = capture.combined.Size(), capture.combined.Lines()
+	}
+
+	return Result{
+		Action:      action,
+		ExitCode:    code,
+		Output:      output,
+		Stdout:      capture.stdout.String(),
+		Stderr:      capture.stderr.String(),
+		TimedOut:    timedOut,
+		StartedAt:   start,
+		FinishedAt:  end,
+		Duration:    end.Sub(start),
+		Assertions:  capture.AssertionResults(),
+		Truncated:   truncated,
+		OutputSize:  size,
+		OutputLines: lines,
+	}
+}
+
+// runFunc runs a single attempt of an action: runAction, or the run method
+// of a shellSession.
+type runFunc func(ctx context.Context, action Action, cfg ExecConfig, onOutput func([]byte)) Result
+
+// runWithRetries runs action until it passes or its retries are exhausted,
+// waiting between attempts with exponential backoff. The result describes the
+// last attempt and lists every attempt. Retrying stops early when ctx is done.
+func runWithRetries(ctx context.Context, action Action, cfg ExecConfig, run runFunc, onOutput func([]byte)) Result {
+	retries := cfg.EffectiveRetries(action)
+
+	var attempts []Attempt
+	for n := 0; ; n++ {
+		result := run(ctx, action, cfg, onOutput)
+		attempts = append(attempts, Attempt{
+			ExitCode: result.ExitCode,
+			TimedOut: result.TimedOut,
+			Output:   result.Output,
+			Duration: result.Duration,
 		})
 
-		if onError == Stop && code != 0 {
+		if result.Passed() || n >= retries || !sleep(ctx, cfg.retryDelay(n+1)) {
+			result.Attempts = attempts
+			return result
+		}
+	}
+}
+
+// sleep waits for d and reports whether it did so before ctx was done.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// ExecuteActions runs the actions using cfg.Shell and collects results.
+//
+// Up to cfg.Jobs actions run concurrently, started in input order, and the
+// returned results always follow input order. Actions that do not pass are
+// retried as set by cfg.Retries and Action.Retries. If cfg.OnError is Stop and
+// an action still fails once its retries are exhausted (see Result.Failed), no
+// further actions are started; actions already running finish and their
+// results are kept. Because an action only starts once a previous one has
+// released its slot, the set of started actions is always a prefix of the
+// input when no action has needs, so partial results are deterministic for a
+// given Jobs.
+//
+// An action that needs others (see Action.Needs) waits until they finish,
+// while later actions that are ready start ahead of it, so independent
+// branches of the graph run concurrently. If any of its needs did not pass,
+// the action is not run and its result is marked Skipped; OnResult is called
+// for it, but OnStart is not. Needs naming no action in actions are ignored.
+// Actions that never started nor were skipped are left out of the results.
+//
+// With cfg.Session, actions run one at a time in a single shell (see
+// shellSession) instead of each in its own, whatever cfg.Jobs says. Otherwise
+// each action runs through cfg.Transport, on this machine when it is nil.
+//
+// Cancelling ctx kills running actions and prevents new ones from starting.
+func ExecuteActions(ctx context.Context, actions []Action, cfg ExecConfig) []Result {
+	jobs := cfg.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// The host name is only metadata; an error leaves it empty.
+	shell, hostname := cfg.Shell, ""
+	if host := cfg.transport().Host(); host != "" {
+		shell, hostname = "", host
+	} else {
+		hostname, _ = os.Hostname()
+	}
+
+	run := runFunc(runAction)
+	if cfg.Session {
+		session := newShellSession(cfg.Shell)
+		defer session.close()
+		run = session.run
+		jobs = 1
+	}
+
+	deps := dependencies(actions)
+	results := make([]Result, len(actions))
+	states := make([]actionState, len(actions))
+	slots := make(chan struct{}, jobs)
+	finished := make(chan struct{}, 1)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stopped bool
+	)
+
+	for {
+		slots <- struct{}{}
+
+		mu.Lock()
+		if stopped || ctx.Err() != nil {
+			mu.Unlock()
 			break
 		}
+
+		i, ready := nextAction(states, deps)
+		if !ready {
+			running := slices.Contains(states, actionRunning)
+			mu.Unlock()
+			<-slots
+			if !running {
+				break
+			}
+			<-finished
+			continue
+		}
+
+		action := actions[i]
+		if blockedBy := unmetNeeds(deps[i], results); len(blockedBy) > 0 {
+			results[i] = Result{Action: action, ExitCode: -1, Skipped: true, BlockedBy: blockedBy, Shell: shell, Hostname: hostname}
+			states[i] = actionDone
+			if cfg.OnResult != nil {
+				cfg.OnResult(i, results[i])
+			}
+			mu.Unlock()
+			<-slots
+			continue
+		}
+
+		states[i] = actionRunning
+		if cfg.OnStart != nil {
+			cfg.OnStart(i, action)
+		}
+		mu.Unlock()
+
+		var onOutput func([]byte)
+		if cfg.OnOutput != nil {
+			onOutput = func(chunk []byte) { cfg.OnOutput(i, chunk) }
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := runWithRetries(ctx, action, cfg, run, onOutput)
+			result.Shell, result.Hostname = shell, hostname
+
+			mu.Lock()
+			results[i] = result
+			states[i] = actionDone
+			if cfg.OnError == Stop && result.Failed() {
+				stopped = true
+			}
+			if cfg.OnResult != nil {
+				cfg.OnResult(i, result)
+			}
+			mu.Unlock()
+
+			<-slots
+			select {
+			case finished <- struct{}{}:
+			default:
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	collected := []Result{}
+	for i, state := range states {
+		if state == actionDone {
+			collected = append(collected, results[i])
+		}
+	}
+	return collected
+}
+
+// actionState tracks an action through ExecuteActions.
+type actionState int
+
+const (
+	actionPending actionState = iota
+	actionRunning
+	actionDone
+)
+
+// nextAction returns the first pending action whose needs have all finished,
+// and whether there is one.
+func nextAction(states []actionState, deps [][]int) (int, bool) {
+	for i, state := range states {
+		if state != actionPending {
+			continue
+		}
+		ready := true
+		for _, j := range deps[i] {
+			if states[j] != actionDone {
+				ready = false
+				break
+			}
+		}
+		if ready {
+			return i, true
+		}
 	}
+	return 0, false
+}
 
-	return results
+// unmetNeeds returns the names of the needs at indices deps whose results did
+// not pass.
+func unmetNeeds(deps []int, results []Result) []string {
+	var unmet []string
+	for _, j := range deps {
+		if !results[j].Passed() {
+			unmet = append(unmet, results[j].Action.Name)
+		}
+	}
+	return unmet
 }
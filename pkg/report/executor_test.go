@@ -0,0 +1,689 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecuteActions(t *testing.T) {
+	t.Run("serial run keeps every result", func(t *testing.T) {
+		actions := []Action{
+			{Description: "first", Command: "echo one"},
+			{Description: "second", Command: "exit 3"},
+			{Description: "third", Command: "echo three"},
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Continue, Jobs: 1})
+
+		if len(got) != 3 {
+			t.Fatalf("got %d results, want 3", len(got))
+		}
+		if strings.TrimSpace(got[0].Output) != "one" {
+			t.Errorf("result[0].Output = %q, want %q", got[0].Output, "one")
+		}
+		if got[1].ExitCode != 3 {
+			t.Errorf("result[1].ExitCode = %d, want 3", got[1].ExitCode)
+		}
+		if strings.TrimSpace(got[2].Output) != "three" {
+			t.Errorf("result[2].Output = %q, want %q", got[2].Output, "three")
+		}
+	})
+
+	t.Run("parallel results follow input order", func(t *testing.T) {
+		// Later actions finish first, so completion order is the reverse of input order.
+		actions := []Action{
+			{Command: "sleep 0.3; echo a"},
+			{Command: "sleep 0.2; echo b"},
+			{Command: "sleep 0.1; echo c"},
+			{Command: "echo d"},
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Continue, Jobs: 4})
+
+		want := []string{"a", "b", "c", "d"}
+		if len(got) != len(want) {
+			t.Fatalf("got %d results, want %d", len(got), len(want))
+		}
+		for i, w := range want {
+			if got[i].Action.Command != actions[i].Command {
+				t.Errorf("result[%d].Action = %q, want %q", i, got[i].Action.Command, actions[i].Command)
+			}
+			if strings.TrimSpace(got[i].Output) != w {
+				t.Errorf("result[%d].Output = %q, want %q", i, got[i].Output, w)
+			}
+		}
+	})
+
+	t.Run("parallel run is faster than serial", func(t *testing.T) {
+		actions := make([]Action, 4)
+		for i := range actions {
+			actions[i] = Action{Command: "sleep 0.2"}
+		}
+
+		start := time.Now()
+		ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Continue, Jobs: 4})
+		if elapsed := time.Since(start); elapsed > 700*time.Millisecond {
+			t.Errorf("parallel run took %s, expected actions to overlap", elapsed)
+		}
+	})
+
+	t.Run("serial stop halts after first failure", func(t *testing.T) {
+		actions := []Action{
+			{Command: "echo ok"},
+			{Command: "exit 1"},
+			{Command: "echo never"},
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Stop, Jobs: 1})
+
+		if len(got) != 2 {
+			t.Fatalf("got %d results, want 2", len(got))
+		}
+		if got[1].ExitCode != 1 {
+			t.Errorf("result[1].ExitCode = %d, want 1", got[1].ExitCode)
+		}
+	})
+
+	t.Run("parallel stop cancels not-yet-started actions", func(t *testing.T) {
+		// With two slots, actions 0 and 1 start together. Action 0 fails
+		// immediately, so no later action may start; action 1 was already
+		// running and its result is kept.
+		actions := []Action{
+			{Command: "exit 1"},
+			{Command: "sleep 0.2; echo running"},
+			{Command: "echo never"},
+			{Command: "echo never"},
+		}
+
+		for run := 0; run < 5; run++ {
+			got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Stop, Jobs: 2})
+
+			if len(got) != 2 {
+				t.Fatalf("run %d: got %d results, want 2: %+v", run, len(got), got)
+			}
+			if got[0].ExitCode != 1 {
+				t.Errorf("run %d: result[0].ExitCode = %d, want 1", run, got[0].ExitCode)
+			}
+			if strings.TrimSpace(got[1].Output) != "running" {
+				t.Errorf("run %d: result[1].Output = %q, want %q", run, got[1].Output, "running")
+			}
+		}
+	})
+
+	t.Run("jobs below one runs serially", func(t *testing.T) {
+		actions := make([]Action, 3)
+		for i := range actions {
+			actions[i] = Action{Command: fmt.Sprintf("echo %d", i)}
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Continue})
+
+		if len(got) != 3 {
+			t.Fatalf("got %d results, want 3", len(got))
+		}
+		for i := range got {
+			if strings.TrimSpace(got[i].Output) != fmt.Sprint(i) {
+				t.Errorf("result[%d].Output = %q, want %q", i, got[i].Output, fmt.Sprint(i))
+			}
+		}
+	})
+}
+
+func TestExecuteActionsOnResult(t *testing.T) {
+	actions := []Action{
+		{Command: "sleep 0.2; echo slow"},
+		{Command: "echo fast"},
+	}
+
+	var order []int
+	got := ExecuteActions(context.Background(), actions, ExecConfig{
+		Shell: "/bin/sh",
+		Jobs:  2,
+		OnResult: func(index int, result Result) {
+			order = append(order, index)
+			if result.Action.Command != actions[index].Command {
+				t.Errorf("OnResult(%d) got action %q, want %q", index, result.Action.Command, actions[index].Command)
+			}
+		},
+	})
+
+	if len(got) != 2 {
+		t.Fatalf("got %d results, want 2", len(got))
+	}
+	// The fast action finishes first, so it is reported first.
+	if len(order) != 2 || order[0] != 1 || order[1] != 0 {
+		t.Errorf("OnResult order = %v, want [1 0]", order)
+	}
+	if got[0].StartedAt.IsZero() || got[0].FinishedAt.Before(got[0].StartedAt) {
+		t.Errorf("result[0] timestamps = %s..%s, want a valid interval", got[0].StartedAt, got[0].FinishedAt)
+	}
+}
+
+func TestExecuteActionsLiveHooks(t *testing.T) {
+	actions := []Action{
+		{Command: "echo one; sleep 0.05; echo two >&2"},
+		{Command: "echo three"},
+	}
+
+	var (
+		mu      sync.Mutex
+		started []int
+		output  = map[int]string{}
+	)
+	ExecuteActions(context.Background(), actions, ExecConfig{
+		Shell: "/bin/sh",
+		Jobs:  2,
+		OnStart: func(index int, action Action) {
+			started = append(started, index)
+			if action.Command != actions[index].Command {
+				t.Errorf("OnStart(%d) got action %q, want %q", index, action.Command, actions[index].Command)
+			}
+		},
+		OnOutput: func(index int, chunk []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			output[index] += string(chunk)
+		},
+	})
+
+	if len(started) != 2 || started[0] != 0 || started[1] != 1 {
+		t.Errorf("OnStart order = %v, want [0 1]", started)
+	}
+	if output[0] != "one\ntwo\n" {
+		t.Errorf("OnOutput for action 0 = %q, want %q", output[0], "one\ntwo\n")
+	}
+	if output[1] != "three\n" {
+		t.Errorf("OnOutput for action 1 = %q, want %q", output[1], "three\n")
+	}
+}
+
+func TestExecuteActionsStreams(t *testing.T) {
+	actions := []Action{{Command: "echo out1; echo err1 >&2; sleep 0.05; echo out2; echo err2 >&2"}}
+
+	got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh"})
+
+	if got[0].Stdout != "out1\nout2\n" {
+		t.Errorf("Stdout = %q, want %q", got[0].Stdout, "out1\nout2\n")
+	}
+	if got[0].Stderr != "err1\nerr2\n" {
+		t.Errorf("Stderr = %q, want %q", got[0].Stderr, "err1\nerr2\n")
+	}
+	// The sleep separates the two bursts, so both lines of the first burst
+	// must precede both lines of the second in the interleaved view.
+	lines := strings.Split(strings.TrimSpace(got[0].Output), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Output = %q, want 4 lines", got[0].Output)
+	}
+	first := strings.Join(lines[:2], " ")
+	if !strings.Contains(first, "out1") || !strings.Contains(first, "err1") {
+		t.Errorf("Output = %q, want the first burst before the second", got[0].Output)
+	}
+}
+
+func TestExecuteActionsTimeout(t *testing.T) {
+	t.Run("global timeout kills the action", func(t *testing.T) {
+		actions := []Action{{Command: "echo started; sleep 5"}}
+
+		start := time.Now()
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Timeout: 200 * time.Millisecond})
+		elapsed := time.Since(start)
+
+		if elapsed > 3*time.Second {
+			t.Fatalf("timed-out action took %s to return", elapsed)
+		}
+		if !got[0].TimedOut {
+			t.Error("expected TimedOut to be set")
+		}
+		if got[0].ExitCode != -1 {
+			t.Errorf("ExitCode = %d, want -1", got[0].ExitCode)
+		}
+		if strings.TrimSpace(got[0].Output) != "started" {
+			t.Errorf("Output = %q, want output captured before the kill", got[0].Output)
+		}
+		if got[0].Duration < 200*time.Millisecond {
+			t.Errorf("Duration = %s, want at least the timeout", got[0].Duration)
+		}
+	})
+
+	t.Run("timeout kills the whole process group", func(t *testing.T) {
+		// The background sleep inherits stdout; if it survived the kill,
+		// reading the output would block until it exited.
+		actions := []Action{{Command: "sleep 5 & sleep 5"}}
+
+		start := time.Now()
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Timeout: 200 * time.Millisecond})
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("action with children took %s to return", elapsed)
+		}
+		if !got[0].TimedOut {
+			t.Error("expected TimedOut to be set")
+		}
+	})
+
+	t.Run("per-action timeout overrides the global one", func(t *testing.T) {
+		actions := []Action{
+			{Command: "sleep 0.3; echo done", Timeout: 2 * time.Second},
+			{Command: "sleep 5", Timeout: 100 * time.Millisecond},
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Timeout: 200 * time.Millisecond})
+
+		if got[0].TimedOut || got[0].ExitCode != 0 {
+			t.Errorf("result[0] = %+v, want a normal exit", got[0])
+		}
+		if !got[1].TimedOut {
+			t.Error("result[1]: expected TimedOut to be set")
+		}
+	})
+
+	t.Run("fast action is not marked as timed out", func(t *testing.T) {
+		got := ExecuteActions(context.Background(), []Action{{Command: "exit 2"}}, ExecConfig{Shell: "/bin/sh", Timeout: time.Second})
+
+		if got[0].TimedOut {
+			t.Error("expected TimedOut to be false")
+		}
+		if got[0].ExitCode != 2 {
+			t.Errorf("ExitCode = %d, want 2", got[0].ExitCode)
+		}
+	})
+
+	t.Run("cancelled context stops new actions", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		got := ExecuteActions(ctx, []Action{{Command: "echo never"}}, ExecConfig{Shell: "/bin/sh"})
+
+		if len(got) != 0 {
+			t.Errorf("got %d results, want 0", len(got))
+		}
+	})
+}
+
+func TestExecuteActionsRetries(t *testing.T) {
+	// counter makes a command that fails until its nth run.
+	counter := func(t *testing.T, n int) string {
+		file := filepath.Join(t.TempDir(), "count")
+		return fmt.Sprintf("n=$(cat %[1]s 2>/dev/null || echo 0); n=$((n+1)); echo $n > %[1]s; echo try $n; [ $n -ge %d ]", file, n)
+	}
+
+	t.Run("retries until the action passes", func(t *testing.T) {
+		actions := []Action{{Command: counter(t, 3)}}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Retries: 5})
+
+		if !got[0].Passed() {
+			t.Fatalf("expected the action to pass, got exit code %d", got[0].ExitCode)
+		}
+		if got[0].AttemptCount() != 3 {
+			t.Fatalf("AttemptCount() = %d, want 3", got[0].AttemptCount())
+		}
+		for i, a := range got[0].Attempts {
+			wantExit := 1
+			if i == 2 {
+				wantExit = 0
+			}
+			if a.ExitCode != wantExit || a.Output != fmt.Sprintf("try %d\n", i+1) {
+				t.Errorf("attempt %d = %+v, want exit %d and its own output", i+1, a, wantExit)
+			}
+		}
+		if got[0].Output != "try 3\n" {
+			t.Errorf("Output = %q, want the last attempt's output", got[0].Output)
+		}
+	})
+
+	t.Run("gives up when retries are exhausted", func(t *testing.T) {
+		actions := []Action{{Command: "false"}}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Retries: 2})
+
+		if got[0].AttemptCount() != 3 || !got[0].Failed() {
+			t.Errorf("got %d attempts, failed=%v; want 3 attempts and a failure", got[0].AttemptCount(), got[0].Failed())
+		}
+	})
+
+	t.Run("action overrides the global retries", func(t *testing.T) {
+		actions := []Action{{Command: "false", Retries: 1}, {Command: "false"}}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Retries: 3})
+
+		if got[0].AttemptCount() != 2 || got[1].AttemptCount() != 4 {
+			t.Errorf("attempts = %d and %d, want 2 and 4", got[0].AttemptCount(), got[1].AttemptCount())
+		}
+	})
+
+	t.Run("passing action runs once", func(t *testing.T) {
+		got := ExecuteActions(context.Background(), []Action{{Command: "true"}}, ExecConfig{Shell: "/bin/sh", Retries: 3})
+
+		if len(got[0].Attempts) != 1 {
+			t.Errorf("got %d attempts, want 1", len(got[0].Attempts))
+		}
+	})
+
+	t.Run("stop waits for retries", func(t *testing.T) {
+		actions := []Action{{Command: counter(t, 2)}, {Command: "echo next"}}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Stop, Retries: 1})
+
+		if len(got) != 2 {
+			t.Errorf("got %d results, want 2: a retried success must not stop the run", len(got))
+		}
+	})
+
+	t.Run("backoff doubles the delay", func(t *testing.T) {
+		actions := []Action{{Command: "false"}}
+
+		start := time.Now()
+		ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Retries: 2, RetryDelay: 100 * time.Millisecond})
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+			t.Errorf("two retries took %s, want at least 100ms + 200ms of backoff", elapsed)
+		}
+	})
+
+	t.Run("cancellation stops retrying", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		got := ExecuteActions(ctx, []Action{{Command: "false"}}, ExecConfig{Shell: "/bin/sh", Retries: 5, RetryDelay: time.Second})
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("cancelled retries took %s to return", elapsed)
+		}
+		if got[0].AttemptCount() != 1 {
+			t.Errorf("got %d attempts, want 1", got[0].AttemptCount())
+		}
+	})
+}
+
+func TestExecuteActionsNeeds(t *testing.T) {
+	t.Run("skips dependents of a failed action", func(t *testing.T) {
+		actions := []Action{
+			{Name: "build", Command: "exit 2"},
+			{Name: "test", Command: "echo test", Needs: []string{"build"}},
+			{Command: "echo deploy", Needs: []string{"test"}},
+			{Command: "echo unrelated"},
+		}
+
+		var mu sync.Mutex
+		var started []int
+		results := map[int]Result{}
+		cfg := ExecConfig{
+			Shell: "/bin/sh",
+			OnStart: func(index int, action Action) {
+				mu.Lock()
+				started = append(started, index)
+				mu.Unlock()
+			},
+			OnResult: func(index int, r Result) {
+				results[index] = r
+			},
+		}
+
+		got := ExecuteActions(context.Background(), actions, cfg)
+
+		if len(got) != 4 {
+			t.Fatalf("got %d results, want 4", len(got))
+		}
+		if !got[1].Skipped || !reflect.DeepEqual(got[1].BlockedBy, []string{"build"}) {
+			t.Errorf("result[1] skipped=%v blocked by %q, want skipped by build", got[1].Skipped, got[1].BlockedBy)
+		}
+		if !got[2].Skipped || !reflect.DeepEqual(got[2].BlockedBy, []string{"test"}) {
+			t.Errorf("result[2] skipped=%v blocked by %q, want skipped by test", got[2].Skipped, got[2].BlockedBy)
+		}
+		if got[1].Output != "" || got[1].ExitCode != -1 || got[1].Failed() {
+			t.Errorf("skipped result = %+v, want no output, exit -1, and not a failure", got[1])
+		}
+		if strings.TrimSpace(got[3].Output) != "unrelated" {
+			t.Errorf("result[3].Output = %q, want the unrelated action to run", got[3].Output)
+		}
+		if !reflect.DeepEqual(started, []int{0, 3}) {
+			t.Errorf("OnStart called for %v, want [0 3]", started)
+		}
+		if len(results) != 4 || !results[2].Skipped {
+			t.Errorf("OnResult called for %d actions, want 4 including the skipped ones", len(results))
+		}
+	})
+
+	t.Run("runs an action once its needs pass", func(t *testing.T) {
+		actions := []Action{
+			{Command: "echo after", Needs: []string{"setup"}},
+			{Name: "setup", Command: "echo ready"},
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh"})
+
+		if len(got) != 2 || got[0].Skipped || !got[0].Passed() {
+			t.Fatalf("got %+v, want both actions to run and pass", got)
+		}
+		if got[0].StartedAt.Before(got[1].FinishedAt) {
+			t.Errorf("action started at %s, before its need finished at %s", got[0].StartedAt, got[1].FinishedAt)
+		}
+	})
+
+	t.Run("ready actions start ahead of waiting ones", func(t *testing.T) {
+		actions := []Action{
+			{Name: "slow", Command: "sleep 0.3"},
+			{Command: "true", Needs: []string{"slow"}},
+			{Command: "true"},
+		}
+
+		var mu sync.Mutex
+		var started []int
+		cfg := ExecConfig{Shell: "/bin/sh", Jobs: 2, OnStart: func(index int, action Action) {
+			mu.Lock()
+			started = append(started, index)
+			mu.Unlock()
+		}}
+
+		ExecuteActions(context.Background(), actions, cfg)
+
+		if !reflect.DeepEqual(started, []int{0, 2, 1}) {
+			t.Errorf("start order = %v, want [0 2 1]", started)
+		}
+	})
+
+	t.Run("independent branches run concurrently", func(t *testing.T) {
+		actions := []Action{
+			{Name: "a", Command: "sleep 0.3"},
+			{Name: "b", Command: "sleep 0.3"},
+			{Command: "true", Needs: []string{"a", "b"}},
+		}
+
+		start := time.Now()
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Jobs: 2})
+		if elapsed := time.Since(start); elapsed > 550*time.Millisecond {
+			t.Errorf("run took %s, want the two branches to overlap", elapsed)
+		}
+		if !got[2].Passed() {
+			t.Errorf("joining action did not pass: %+v", got[2])
+		}
+	})
+
+	t.Run("stop leaves dependents out", func(t *testing.T) {
+		actions := []Action{
+			{Name: "build", Command: "false"},
+			{Command: "true", Needs: []string{"build"}},
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Stop})
+
+		if len(got) != 1 {
+			t.Errorf("got %d results, want 1", len(got))
+		}
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	cfg := ExecConfig{RetryDelay: 4 * time.Second}
+
+	for n, want := range map[int]time.Duration{1: 4 * time.Second, 2: 8 * time.Second, 3: 16 * time.Second, 4: maxRetryDelay, 10: maxRetryDelay} {
+		if got := cfg.retryDelay(n); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestExecuteActionsDirectives(t *testing.T) {
+	t.Run("cwd and env are applied", func(t *testing.T) {
+		dir := t.TempDir()
+		actions := []Action{{Command: `pwd; echo "$GREETING"`, Dir: dir, Env: []string{"GREETING=hi"}}}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh"})
+
+		lines := strings.Split(strings.TrimSpace(got[0].Stdout), "\n")
+		if len(lines) != 2 || !strings.HasSuffix(lines[0], filepath.Base(dir)) || lines[1] != "hi" {
+			t.Errorf("Stdout = %q, want the temp dir and the greeting", got[0].Stdout)
+		}
+	})
+
+	t.Run("missing cwd is reported", func(t *testing.T) {
+		actions := []Action{{Command: "true", Dir: "/does/not/exist"}}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh"})
+
+		if got[0].ExitCode == 0 {
+			t.Error("expected a non-zero exit code")
+		}
+		if !strings.Contains(got[0].Stderr, "/does/not/exist") {
+			t.Errorf("Stderr = %q, want the start error", got[0].Stderr)
+		}
+	})
+
+	t.Run("expected exit code does not stop the run", func(t *testing.T) {
+		actions := []Action{
+			{Command: "exit 1", ExpectExit: 1},
+			{Command: "echo next"},
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Stop})
+
+		if len(got) != 2 {
+			t.Fatalf("got %d results, want 2", len(got))
+		}
+		if !got[0].Passed() {
+			t.Error("result[0] should pass with its expected exit code")
+		}
+	})
+
+	t.Run("unexpected success stops the run", func(t *testing.T) {
+		actions := []Action{
+			{Command: "true", ExpectExit: 1},
+			{Command: "echo never"},
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Stop})
+
+		if len(got) != 1 {
+			t.Fatalf("got %d results, want 1", len(got))
+		}
+	})
+
+	t.Run("allowed failure does not stop the run", func(t *testing.T) {
+		actions := []Action{
+			{Command: "exit 4", AllowFailure: true},
+			{Command: "echo next"},
+		}
+
+		got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Stop})
+
+		if len(got) != 2 {
+			t.Fatalf("got %d results, want 2", len(got))
+		}
+		if got[0].Passed() || got[0].Failed() {
+			t.Errorf("result[0] Passed=%v Failed=%v, want neither", got[0].Passed(), got[0].Failed())
+		}
+	})
+}
+
+func TestExecuteActionsAssertions(t *testing.T) {
+	actions := []Action{
+		{
+			Command: "echo v1.2 ready",
+			Assertions: []Assertion{
+				{Kind: OutputContains, Pattern: "ready"},
+				{Kind: OutputMatches, Pattern: `^v\d+\.`},
+			},
+		},
+		{
+			Command:    "echo starting",
+			Assertions: []Assertion{{Kind: OutputContains, Pattern: "ready"}},
+		},
+		{Command: "echo never"},
+	}
+
+	got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", OnError: Stop})
+
+	if len(got) != 2 {
+		t.Fatalf("got %d results, want 2 (a failed assertion stops the run)", len(got))
+	}
+	if !got[0].Passed() || len(got[0].Assertions) != 2 {
+		t.Errorf("result[0] = %+v, want both assertions to pass", got[0].Assertions)
+	}
+	if got[1].Passed() || len(got[1].FailedAssertions()) != 1 {
+		t.Errorf("result[1] = %+v, want one failed assertion", got[1].Assertions)
+	}
+}
+
+func TestExecuteActionsMetadata(t *testing.T) {
+	dir := t.TempDir()
+	actions := []Action{
+		{Command: "i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done"},
+		{Command: "pwd", Dir: dir},
+	}
+
+	got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh"})
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+
+	for i, want := range []string{wd, dir} {
+		r := got[i]
+		if r.Dir != want || r.Shell != "/bin/sh" || r.Hostname != hostname {
+			t.Errorf("result[%d] ran in %q with %q on %q; want %q, /bin/sh, %q", i, r.Dir, r.Shell, r.Hostname, want, hostname)
+		}
+		if r.StartedAt.IsZero() || r.FinishedAt.Before(r.StartedAt) {
+			t.Errorf("result[%d] timestamps = %v, %v", i, r.StartedAt, r.FinishedAt)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		if got[0].UserTime+got[0].SystemTime == 0 {
+			t.Errorf("result[0] = %v user, %v system; want the command's CPU time", got[0].UserTime, got[0].SystemTime)
+		}
+	}
+}
+
+func TestExecuteActionsMaxRSS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("max RSS is not reported on Windows")
+	}
+
+	const size = 64 << 20
+	actions := []Action{
+		{Command: fmt.Sprintf("x=$(head -c %d /dev/zero | tr '\\0' a); echo ${#x}", size)},
+		{Command: "echo a"},
+	}
+
+	got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh"})
+
+	if got[0].MaxRSS < size {
+		t.Errorf("result[0].MaxRSS = %d, want at least the %d bytes the shell held", got[0].MaxRSS, size)
+	}
+
+	// On Linux a child's peak starts at this process's own, so a small
+	// command's figure only measures this process and is left out.
+	if runtime.GOOS == "linux" && got[1].MaxRSS != 0 {
+		t.Errorf("result[1].MaxRSS = %d, want 0 for a command smaller than this process", got[1].MaxRSS)
+	}
+}
@@ -4,34 +4,301 @@ import (
 	"encoding/xml"
 	"fmt"
 	"strings"
+	"time"
 )
 
+func init() {
+	RegisterFormatter(XML, NewFormatter(".xml", FormatXML))
+	RegisterFormatter(Markdown, NewFormatter(".md", func(results []Result) (string, error) {
+		return FormatMarkdown(results), nil
+	}))
+}
+
 // xmlReport is the top-level XML structure for marshalling.
 type xmlReport struct {
-	XMLName xml.Name    `xml:"report"`
-	Actions []xmlAction `xml:"action"`
+	XMLName    xml.Name    `xml:"report"`
+	Redactions int         `xml:"redactions,attr,omitempty"`
+	Actions    []xmlAction `xml:"action"`
+	Summary    *xmlSummary `xml:"summary,omitempty"`
+}
+
+// xmlSummary is the footer of a report: the run's total time, its slowest
+// action (numbered from 1), its resource totals, and where it ran.
+type xmlSummary struct {
+	Total      string `xml:"total,attr"`
+	Slowest    int    `xml:"slowest,attr"`
+	UserTime   string `xml:"user-time,attr,omitempty"`
+	SystemTime string `xml:"system-time,attr,omitempty"`
+	MaxRSS     int    `xml:"max-rss,attr,omitempty"`
+	Hostname   string `xml:"hostname,attr,omitempty"`
+	Shell      string `xml:"shell,attr,omitempty"`
+	Dir        string `xml:"cwd,attr,omitempty"`
+}
+
+// newXMLSummary summarizes results; it returns nil when no action ran.
+func newXMLSummary(results []Result) *xmlSummary {
+	s := summarize(results)
+	if s.Slowest < 0 {
+		return nil
+	}
+	out := &xmlSummary{
+		Total:    formatDuration(s.Total),
+		Slowest:  s.Slowest + 1,
+		MaxRSS:   s.MaxRSS,
+		Hostname: s.Hostname,
+		Shell:    s.Shell,
+		Dir:      s.Dir,
+	}
+	if s.UserTime > 0 || s.SystemTime > 0 {
+		out.UserTime, out.SystemTime = formatDuration(s.UserTime), formatDuration(s.SystemTime)
+	}
+	return out
 }
 
 // xmlAction represents a single action in the XML output.
 type xmlAction struct {
-	Description string `xml:"description"`
-	Command     string `xml:"command"`
-	Status      int    `xml:"status"`
-	Output      string `xml:"output"`
+	Name           string         `xml:"name,omitempty"`
+	Description    string         `xml:"description"`
+	Command        string         `xml:"command"`
+	Tags           []string       `xml:"tags>tag,omitempty"`
+	Needs          *xmlNeeds      `xml:"needs,omitempty"`
+	Skipped        *xmlNeeds      `xml:"skipped,omitempty"`
+	Status         int            `xml:"status"`
+	ExpectedStatus int            `xml:"expected-status,omitempty"`
+	AllowFailure   bool           `xml:"allow-failure,omitempty"`
+	Timeout        string         `xml:"timeout,omitempty"`
+	Retries        int            `xml:"retries,omitempty"`
+	TimedOut       bool           `xml:"timed-out,omitempty"`
+	Duration       string         `xml:"duration"`
+	Started        string         `xml:"started,omitempty"`
+	Finished       string         `xml:"finished,omitempty"`
+	Resources      *xmlResources  `xml:"resources,omitempty"`
+	Dir            string         `xml:"cwd,omitempty"`
+	Shell          string         `xml:"shell,omitempty"`
+	Hostname       string         `xml:"hostname,omitempty"`
+	Attempts       *xmlAttempts   `xml:"attempts,omitempty"`
+	Assertions     []xmlAssertion `xml:"assertions>assertion,omitempty"`
+	Redactions     int            `xml:"redactions,omitempty"`
+	Truncated      *xmlTruncated  `xml:"truncated,omitempty"`
+	Stdout         string         `xml:"stdout"`
+	Stderr         string         `xml:"stderr"`
+	Output         string         `xml:"output"`
+}
+
+// xmlNeeds lists action names: the needs of an action, or the needs that
+// kept a skipped action from running.
+type xmlNeeds struct {
+	Names []string `xml:"need"`
+}
+
+// newXMLNeeds wraps names in their XML form; it returns nil for no names.
+func newXMLNeeds(names []string) *xmlNeeds {
+	if len(names) == 0 {
+		return nil
+	}
+	return &xmlNeeds{Names: names}
+}
+
+// names returns the listed names; it is safe to call on nil.
+func (n *xmlNeeds) names() []string {
+	if n == nil {
+		return nil
+	}
+	return n.Names
+}
+
+// xmlTruncated marks an action whose output went over the limits, with the
+// size of its whole combined output.
+type xmlTruncated struct {
+	Size  int `xml:"size,attr"`
+	Lines int `xml:"lines,attr"`
+}
+
+// newXMLTruncated returns the truncation marker of r, or nil when its output
+// was kept whole.
+func newXMLTruncated(r Result) *xmlTruncated {
+	if !r.Truncated {
+		return nil
+	}
+	return &xmlTruncated{Size: r.OutputSize, Lines: r.OutputLines}
+}
+
+// xmlResources records the CPU time and peak memory of an action.
+type xmlResources struct {
+	UserTime   string `xml:"user-time,attr"`
+	SystemTime string `xml:"system-time,attr"`
+	MaxRSS     int    `xml:"max-rss,attr,omitempty"`
+}
+
+// newXMLResources returns the resource usage of r, or nil when none was
+// reported.
+func newXMLResources(r Result) *xmlResources {
+	if r.UserTime == 0 && r.SystemTime == 0 && r.MaxRSS == 0 {
+		return nil
+	}
+	return &xmlResources{UserTime: formatDuration(r.UserTime), SystemTime: formatDuration(r.SystemTime), MaxRSS: r.MaxRSS}
+}
+
+// xmlTimeout renders an action's own timeout; none is left out.
+func xmlTimeout(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// xmlTime renders t for the XML report; the zero time is left out.
+func xmlTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// xmlAssertion represents the outcome of one output assertion.
+type xmlAssertion struct {
+	Kind    string `xml:"kind,attr"`
+	Passed  bool   `xml:"passed,attr"`
+	Pattern string `xml:",chardata"`
+}
+
+// xmlAttempts lists every attempt of an action that was retried.
+type xmlAttempts struct {
+	Count    int          `xml:"count,attr"`
+	Attempts []xmlAttempt `xml:"attempt"`
+}
+
+// xmlAttempt represents one attempt; its text is the attempt's combined output.
+type xmlAttempt struct {
+	Status   int    `xml:"status,attr"`
+	TimedOut bool   `xml:"timed-out,attr,omitempty"`
+	Duration string `xml:"duration,attr"`
+	Output   string `xml:",chardata"`
+}
+
+// newXMLAttempts converts the attempts of a result into their XML form; it
+// returns nil unless the action ran more than once.
+func newXMLAttempts(attempts []Attempt) *xmlAttempts {
+	if len(attempts) < 2 {
+		return nil
+	}
+	out := &xmlAttempts{Count: len(attempts), Attempts: make([]xmlAttempt, len(attempts))}
+	for i, a := range attempts {
+		out.Attempts[i] = xmlAttempt{
+			Status:   a.ExitCode,
+			TimedOut: a.TimedOut,
+			Duration: formatDuration(a.Duration),
+			Output:   strings.TrimRight(a.Output, "\n"),
+		}
+	}
+	return out
+}
+
+// newXMLAssertions converts assertion results into their XML form.
+func newXMLAssertions(results []AssertionResult) []xmlAssertion {
+	if len(results) == 0 {
+		return nil
+	}
+	out := make([]xmlAssertion, len(results))
+	for i, ar := range results {
+		out[i] = xmlAssertion{Kind: string(ar.Assertion.Kind), Passed: ar.Passed, Pattern: ar.Assertion.Pattern}
+	}
+	return out
+}
+
+// formatDuration renders d rounded to milliseconds, e.g. "1.234s".
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+// statusNote describes anything about how an action ended that its exit code
+// alone does not tell, e.g. "killed: timed out after 30s", "expected 1",
+// "3 attempts", "output truncated from 12.0 MiB, 80000 lines", or "2 secrets
+// redacted". It returns "" for ordinary exits.
+func statusNote(r Result) string {
+	if r.Skipped {
+		return "skipped: needs " + strings.Join(r.BlockedBy, ", ")
+	}
+
+	var notes []string
+
+	if r.TimedOut {
+		notes = append(notes, fmt.Sprintf("killed: timed out after %s", formatDuration(r.Duration)))
+	} else if r.Action.ExpectExit != 0 {
+		notes = append(notes, fmt.Sprintf("expected %d", r.Action.ExpectExit))
+	}
+
+	if n := r.AttemptCount(); n > 1 {
+		notes = append(notes, fmt.Sprintf("%d attempts", n))
+	}
+
+	if r.Truncated {
+		notes = append(notes, fmt.Sprintf("output truncated from %s, %d lines", formatBytes(r.OutputSize), r.OutputLines))
+	}
+
+	if !r.Passed() && r.Action.AllowFailure {
+		notes = append(notes, "failure allowed")
+	}
+
+	if r.Redactions > 0 {
+		notes = append(notes, redactionSummary(r.Redactions))
+	}
+
+	return strings.Join(notes, "; ")
+}
+
+// codeFence returns a backtick fence long enough to wrap content, so output
+// that itself contains ``` cannot close the block early.
+func codeFence(content string) string {
+	longest, run := 0, 0
+	for _, c := range content {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
 }
 
 // FormatXML formats the results as an XML report using encoding/xml for proper escaping.
+// Each action carries <stdout> and <stderr> alongside the combined <output>.
 func FormatXML(results []Result) (string, error) {
 	report := xmlReport{
-		Actions: make([]xmlAction, len(results)),
+		Redactions: CountRedactions(results),
+		Actions:    make([]xmlAction, len(results)),
+		Summary:    newXMLSummary(results),
 	}
 
 	for i, r := range results {
 		report.Actions[i] = xmlAction{
-			Description: r.Action.Description,
-			Command:     r.Action.Command,
-			Status:      r.ExitCode,
-			Output:      strings.TrimRight(r.Output, "\n"),
+			Name:           r.Action.Name,
+			Description:    r.Action.Description,
+			Command:        r.Action.Command,
+			Tags:           r.Action.Tags,
+			Needs:          newXMLNeeds(r.Action.Needs),
+			Skipped:        newXMLNeeds(r.BlockedBy),
+			Status:         r.ExitCode,
+			ExpectedStatus: r.Action.ExpectExit,
+			AllowFailure:   r.Action.AllowFailure,
+			Timeout:        xmlTimeout(r.Action.Timeout),
+			Retries:        r.Action.Retries,
+			TimedOut:       r.TimedOut,
+			Duration:       formatDuration(r.Duration),
+			Started:        xmlTime(r.StartedAt),
+			Finished:       xmlTime(r.FinishedAt),
+			Resources:      newXMLResources(r),
+			Dir:            r.Dir,
+			Shell:          r.Shell,
+			Hostname:       r.Hostname,
+			Attempts:       newXMLAttempts(r.Attempts),
+			Assertions:     newXMLAssertions(r.Assertions),
+			Redactions:     r.Redactions,
+			Truncated:      newXMLTruncated(r),
+			Stdout:         strings.TrimRight(r.Stdout, "\n"),
+			Stderr:         strings.TrimRight(r.Stderr, "\n"),
+			Output:         strings.TrimRight(r.Output, "\n"),
 		}
 	}
 
@@ -42,12 +309,144 @@ func FormatXML(results []Result) (string, error) {
 	return string(out), nil
 }
 
+// ParseXML reads a report written by FormatXML back into results, so saved
+// runs can be compared or re-run. Action options the XML does not carry, such
+// as @env and output limits, are left zero, and outputs lose the trailing
+// newlines FormatXML trims. The summary is derived from the actions, so it is
+// not read back.
+func ParseXML(data []byte) ([]Result, error) {
+	var report xmlReport
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("xml unmarshal: %w", err)
+	}
+
+	results := make([]Result, len(report.Actions))
+	for i, a := range report.Actions {
+		var duration time.Duration
+		if a.Duration != "" {
+			d, err := time.ParseDuration(a.Duration)
+			if err != nil {
+				return nil, fmt.Errorf("action %d: invalid duration %q", i+1, a.Duration)
+			}
+			duration = d
+		}
+
+		var timeout time.Duration
+		if a.Timeout != "" {
+			d, err := time.ParseDuration(a.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("action %d: invalid timeout %q", i+1, a.Timeout)
+			}
+			timeout = d
+		}
+
+		var started, finished time.Time
+		for _, t := range []struct {
+			text  string
+			value *time.Time
+			name  string
+		}{{a.Started, &started, "start"}, {a.Finished, &finished, "finish"}} {
+			if t.text == "" {
+				continue
+			}
+			v, err := time.Parse(time.RFC3339Nano, t.text)
+			if err != nil {
+				return nil, fmt.Errorf("action %d: invalid %s time %q", i+1, t.name, t.text)
+			}
+			*t.value = v
+		}
+
+		var userTime, systemTime time.Duration
+		var rss int
+		if a.Resources != nil {
+			u, uErr := time.ParseDuration(a.Resources.UserTime)
+			sys, sErr := time.ParseDuration(a.Resources.SystemTime)
+			if uErr != nil || sErr != nil {
+				return nil, fmt.Errorf("action %d: invalid CPU time %q/%q", i+1, a.Resources.UserTime, a.Resources.SystemTime)
+			}
+			userTime, systemTime, rss = u, sys, a.Resources.MaxRSS
+		}
+
+		var attempts []Attempt
+		if a.Attempts != nil {
+			for _, xa := range a.Attempts.Attempts {
+				d, err := time.ParseDuration(xa.Duration)
+				if err != nil {
+					return nil, fmt.Errorf("action %d: invalid attempt duration %q", i+1, xa.Duration)
+				}
+				attempts = append(attempts, Attempt{ExitCode: xa.Status, TimedOut: xa.TimedOut, Output: xa.Output, Duration: d})
+			}
+		}
+
+		var assertions []Assertion
+		var outcomes []AssertionResult
+		for _, xa := range a.Assertions {
+			assertion := Assertion{Kind: AssertionKind(xa.Kind), Pattern: xa.Pattern}
+			assertions = append(assertions, assertion)
+			outcomes = append(outcomes, AssertionResult{Assertion: assertion, Passed: xa.Passed})
+		}
+
+		var size, lines int
+		if a.Truncated != nil {
+			size, lines = a.Truncated.Size, a.Truncated.Lines
+		}
+
+		results[i] = Result{
+			Action: Action{
+				Name:         a.Name,
+				Needs:        a.Needs.names(),
+				Description:  a.Description,
+				Command:      a.Command,
+				Timeout:      timeout,
+				Retries:      a.Retries,
+				ExpectExit:   a.ExpectedStatus,
+				AllowFailure: a.AllowFailure,
+				Tags:         a.Tags,
+				Assertions:   assertions,
+			},
+			ExitCode:    a.Status,
+			Output:      a.Output,
+			Stdout:      a.Stdout,
+			Stderr:      a.Stderr,
+			TimedOut:    a.TimedOut,
+			StartedAt:   started,
+			FinishedAt:  finished,
+			Duration:    duration,
+			UserTime:    userTime,
+			SystemTime:  systemTime,
+			MaxRSS:      rss,
+			Dir:         a.Dir,
+			Shell:       a.Shell,
+			Hostname:    a.Hostname,
+			Assertions:  outcomes,
+			Attempts:    attempts,
+			Skipped:     a.Skipped != nil,
+			BlockedBy:   a.Skipped.names(),
+			Redactions:  a.Redactions,
+			Truncated:   a.Truncated != nil,
+			OutputSize:  size,
+			OutputLines: lines,
+		}
+	}
+
+	return results, nil
+}
+
 // FormatMarkdown formats the results as a Markdown report.
+// Actions that wrote to stderr get separate Stdout and Stderr blocks; all
+// others keep the single Output block. A footer after a horizontal rule
+// summarizes the run.
 func FormatMarkdown(results []Result) string {
 	var b strings.Builder
 
 	b.WriteString("# Report")
 
+	if n := CountRedactions(results); n > 0 {
+		fmt.Fprintf(&b, "\n\n_%s_", redactionSummary(n))
+	}
+
+	summary := summarize(results)
+
 	for i, r := range results {
 		fmt.Fprintf(&b, "\n\n## Command %d\n", i+1)
 
@@ -55,25 +454,85 @@ func FormatMarkdown(results []Result) string {
 			fmt.Fprintf(&b, "\n%s\n", r.Action.Description)
 		}
 
-		fmt.Fprintf(&b, "\n**Status Code**: %d\n", r.ExitCode)
+		if note := statusNote(r); note != "" {
+			fmt.Fprintf(&b, "\n**Status Code**: %d (%s)\n", r.ExitCode, note)
+		} else {
+			fmt.Fprintf(&b, "\n**Status Code**: %d\n", r.ExitCode)
+		}
+
+		fmt.Fprintf(&b, "\n**Duration**: %s\n", formatDuration(r.Duration))
 
-		fmt.Fprintf(&b, "\n```\n%s\n```\n", r.Action.Command)
+		if note := resourceNote(r.UserTime, r.SystemTime, r.MaxRSS); note != "" {
+			fmt.Fprintf(&b, "\n**Resources**: %s\n", note)
+		}
 
-		output := strings.TrimRight(r.Output, "\n")
-		fmt.Fprintf(&b, "\n**Output**:\n\n```\n%s\n```", output)
+		if r.Hostname != "" && r.Hostname != summary.Hostname {
+			fmt.Fprintf(&b, "\n**Host**: %s\n", r.Hostname)
+		}
+
+		if r.Dir != "" && r.Dir != summary.Dir {
+			fmt.Fprintf(&b, "\n**Directory**: %s\n", r.Dir)
+		}
+
+		if len(r.Action.Tags) > 0 {
+			fmt.Fprintf(&b, "\n**Tags**: %s\n", strings.Join(r.Action.Tags, ", "))
+		}
+
+		if failed := r.FailedAssertions(); len(failed) > 0 {
+			b.WriteString("\n**Failed Assertions**:\n\n")
+			for _, a := range failed {
+				fmt.Fprintf(&b, "- %s\n", a)
+			}
+		}
+
+		fence := codeFence(r.Action.Command)
+		fmt.Fprintf(&b, "\n%s\n%s\n%s\n", fence, r.Action.Command, fence)
+
+		if r.Stderr == "" {
+			output := strings.TrimRight(r.Output, "\n")
+			fence := codeFence(output)
+			fmt.Fprintf(&b, "\n**Output**:\n\n%s\n%s\n%s", fence, output, fence)
+		} else {
+			stdout := strings.TrimRight(r.Stdout, "\n")
+			stderr := strings.TrimRight(r.Stderr, "\n")
+			fence := codeFence(stdout)
+			fmt.Fprintf(&b, "\n**Stdout**:\n\n%s\n%s\n%s\n", fence, stdout, fence)
+			fence = codeFence(stderr)
+			fmt.Fprintf(&b, "\n**Stderr**:\n\n%s\n%s\n%s", fence, stderr, fence)
+		}
 	}
 
+	writeMarkdownSummary(&b, summary, results)
+
 	return b.String()
 }
 
-// FormatReport dispatches to the appropriate formatter based on the format.
+// writeMarkdownSummary writes the footer: the total time, the slowest action,
+// the resource totals, and where the actions ran. Nothing is written when no
+// action ran.
+func writeMarkdownSummary(b *strings.Builder, s runSummary, results []Result) {
+	if s.Slowest < 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n\n---\n\n**Total**: %s · **Slowest**: Command %d (%s)\n",
+		formatDuration(s.Total), s.Slowest+1, formatDuration(results[s.Slowest].Duration))
+
+	if note := resourceNote(s.UserTime, s.SystemTime, s.MaxRSS); note != "" {
+		fmt.Fprintf(b, "\n**Resources**: %s\n", note)
+	}
+
+	if note := environmentNote(s); note != "" {
+		fmt.Fprintf(b, "\n**Environment**: %s\n", note)
+	}
+}
+
+// FormatReport renders results with the Formatter of format; see
+// LookupFormatter.
 func FormatReport(results []Result, format Format) (string, error) {
-	switch format {
-	case XML:
-		return FormatXML(results)
-	case Markdown:
-		return FormatMarkdown(results), nil
-	default:
-		return "", fmt.Errorf("unsupported format: %s", format)
+	f, err := LookupFormatter(format)
+	if err != nil {
+		return "", err
 	}
+	return f.Format(results)
 }
@@ -0,0 +1,204 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/cloudbridgeuy/scripts/pkg/markdown"
+)
+
+func init() {
+	RegisterFormatter(HTML, NewFormatter(".html", FormatHTML))
+}
+
+// htmlTitle is the page title of every HTML report.
+const htmlTitle = "Report"
+
+// verdict classifies a result for display: "passed", "failed", "skipped", or
+// "allowed" for a failure that does not fail the run.
+func verdict(r Result) string {
+	switch {
+	case r.Skipped:
+		return "skipped"
+	case r.Passed():
+		return "passed"
+	case r.Failed():
+		return "failed"
+	default:
+		return "allowed"
+	}
+}
+
+// actionAnchor is the fragment id of the action at index i.
+func actionAnchor(i int) string {
+	return fmt.Sprintf("command-%d", i+1)
+}
+
+// FormatHTML formats the results as a self-contained HTML page built with the
+// same template, stylesheet, and chroma theme as `scripts markdown`, but
+// without its scripts, since a report holds no Mermaid diagrams. A summary
+// table links to one section per action; each section shows the highlighted
+// command and its output in collapsible blocks, open for failed actions. A
+// footer gives the total time, the slowest action, and where the run happened.
+func FormatHTML(results []Result) (string, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", htmlTitle)
+	writeHTMLSummary(&b, results)
+
+	summary := summarize(results)
+	for i, r := range results {
+		if err := writeHTMLAction(&b, i, r, summary); err != nil {
+			return "", err
+		}
+	}
+	writeHTMLFooter(&b, summary, results)
+
+	css, err := markdown.ChromaCSS()
+	if err != nil {
+		return "", fmt.Errorf("chroma css: %w", err)
+	}
+
+	return markdown.BuildStaticPage(b.String(), htmlTitle, css, ""), nil
+}
+
+// writeHTMLSummary writes the totals line and the table of every action.
+func writeHTMLSummary(b *strings.Builder, results []Result) {
+	failed, skipped := CountFailed(results), CountSkipped(results)
+	totals := fmt.Sprintf("%d commands · %d passed · %d failed", len(results), len(results)-failed-skipped, failed)
+	if skipped > 0 {
+		totals += fmt.Sprintf(" · %d skipped", skipped)
+	}
+	if redacted := CountRedactions(results); redacted > 0 {
+		totals += " · " + redactionSummary(redacted)
+	}
+	fmt.Fprintf(b, "<p class=\"report-totals\">%s · %s</p>\n", totals, formatDuration(reportSpan(results)))
+
+	if len(results) == 0 {
+		return
+	}
+
+	b.WriteString("<table class=\"report-summary\">\n<thead><tr><th>#</th><th>Command</th><th>Status</th><th>Exit</th><th>Duration</th></tr></thead>\n<tbody>\n")
+	for i, r := range results {
+		anchor := actionAnchor(i)
+		fmt.Fprintf(b, "<tr><td><a href=\"#%s\">%d</a></td><td><a href=\"#%s\">%s</a></td><td>%s</td><td>%d</td><td>%s</td></tr>\n",
+			anchor, i+1, anchor, html.EscapeString(actionLabel(r.Action)), statusBadge(r), r.ExitCode, formatDuration(r.Duration))
+	}
+	b.WriteString("</tbody>\n</table>\n")
+}
+
+// writeHTMLFooter writes the summary of the run: the total time, a link to
+// the slowest action, the resource totals, and where the actions ran.
+func writeHTMLFooter(b *strings.Builder, s runSummary, results []Result) {
+	if s.Slowest < 0 {
+		return
+	}
+
+	b.WriteString("<footer class=\"report-footer\">\n")
+	fmt.Fprintf(b, "<p><strong>Total</strong>: %s · <strong>Slowest</strong>: <a href=\"#%s\">Command %d</a> (%s)</p>\n",
+		formatDuration(s.Total), actionAnchor(s.Slowest), s.Slowest+1, formatDuration(results[s.Slowest].Duration))
+	if note := resourceNote(s.UserTime, s.SystemTime, s.MaxRSS); note != "" {
+		fmt.Fprintf(b, "<p><strong>Resources</strong>: %s</p>\n", note)
+	}
+	if note := environmentNote(s); note != "" {
+		fmt.Fprintf(b, "<p><strong>Environment</strong>: %s</p>\n", html.EscapeString(note))
+	}
+	b.WriteString("</footer>\n")
+}
+
+// writeHTMLAction writes the section for the action at index i; its host and
+// working directory are shown when they differ from those shared by the run.
+func writeHTMLAction(b *strings.Builder, i int, r Result, s runSummary) error {
+	anchor := actionAnchor(i)
+	fmt.Fprintf(b, "<section class=\"report-action %s\" id=\"%s\">\n", verdict(r), anchor)
+	fmt.Fprintf(b, "<h2><a href=\"#%s\">Command %d</a> %s</h2>\n", anchor, i+1, statusBadge(r))
+
+	if r.Action.Description != "" {
+		description, err := markdown.RenderMarkdown([]byte(r.Action.Description))
+		if err != nil {
+			return fmt.Errorf("render description: %w", err)
+		}
+		b.WriteString(description)
+	}
+
+	status := fmt.Sprintf("%d", r.ExitCode)
+	if note := statusNote(r); note != "" {
+		status += " (" + note + ")"
+	}
+	fmt.Fprintf(b, "<p><strong>Status Code</strong>: %s · <strong>Duration</strong>: %s",
+		html.EscapeString(status), formatDuration(r.Duration))
+	if len(r.Action.Tags) > 0 {
+		fmt.Fprintf(b, " · <strong>Tags</strong>: %s", html.EscapeString(strings.Join(r.Action.Tags, ", ")))
+	}
+	if note := resourceNote(r.UserTime, r.SystemTime, r.MaxRSS); note != "" {
+		fmt.Fprintf(b, " · <strong>Resources</strong>: %s", note)
+	}
+	if r.Hostname != "" && r.Hostname != s.Hostname {
+		fmt.Fprintf(b, " · <strong>Host</strong>: %s", html.EscapeString(r.Hostname))
+	}
+	if r.Dir != "" && r.Dir != s.Dir {
+		fmt.Fprintf(b, " · <strong>Directory</strong>: <code>%s</code>", html.EscapeString(r.Dir))
+	}
+	b.WriteString("</p>\n")
+
+	if failed := r.FailedAssertions(); len(failed) > 0 {
+		b.WriteString("<p><strong>Failed Assertions</strong>:</p>\n<ul>\n")
+		for _, a := range failed {
+			fmt.Fprintf(b, "<li><code>%s</code></li>\n", html.EscapeString(a.String()))
+		}
+		b.WriteString("</ul>\n")
+	}
+
+	fence := codeFence(r.Action.Command)
+	command, err := markdown.RenderMarkdown([]byte(fence + "sh\n" + r.Action.Command + "\n" + fence + "\n"))
+	if err != nil {
+		return fmt.Errorf("render command: %w", err)
+	}
+	b.WriteString(command)
+
+	open := r.Failed()
+	if r.Stderr == "" {
+		writeHTMLOutput(b, "Output", r.Output, open)
+	} else {
+		writeHTMLOutput(b, "Stdout", r.Stdout, open)
+		writeHTMLOutput(b, "Stderr", r.Stderr, open)
+	}
+
+	b.WriteString("</section>\n")
+	return nil
+}
+
+// writeHTMLOutput writes a collapsible block holding one output stream,
+// expanded when open is set.
+func writeHTMLOutput(b *strings.Builder, label, output string, open bool) {
+	output = strings.TrimRight(output, "\n")
+
+	attr := ""
+	if open {
+		attr = " open"
+	}
+
+	var lines string
+	switch n := strings.Count(output, "\n") + 1; {
+	case output == "":
+		lines = "empty"
+	case n == 1:
+		lines = "1 line"
+	default:
+		lines = fmt.Sprintf("%d lines", n)
+	}
+
+	fmt.Fprintf(b, "<details class=\"report-output\"%s>\n<summary>%s <span class=\"lines\">(%s)</span></summary>\n", attr, label, lines)
+	fmt.Fprintf(b, "<pre><code>%s</code></pre>\n</details>\n", html.EscapeString(output))
+}
+
+// statusBadge renders the colour-coded verdict of r.
+func statusBadge(r Result) string {
+	v := verdict(r)
+	label := v
+	if v == "allowed" {
+		label = "failed (allowed)"
+	}
+	return fmt.Sprintf("<span class=\"status %s\">%s</span>", v, label)
+}
@@ -0,0 +1,192 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatHTML(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		results []Result
+		verify  func(t *testing.T, output string)
+	}{
+		{
+			name: "summary table links to each action",
+			results: []Result{
+				{
+					Action:     Action{Description: "Say `hello`", Command: "echo hello"},
+					Output:     "hello\n",
+					Stdout:     "hello\n",
+					StartedAt:  start,
+					FinishedAt: start.Add(time.Second),
+					Duration:   time.Second,
+				},
+				{
+					Action:     Action{Command: "exit 2"},
+					ExitCode:   2,
+					StartedAt:  start.Add(time.Second),
+					FinishedAt: start.Add(1500 * time.Millisecond),
+					Duration:   500 * time.Millisecond,
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				for _, want := range []string{
+					"<!DOCTYPE html>",
+					"<title>Report</title>",
+					`<p class="report-totals">2 commands · 1 passed · 1 failed · 1.5s</p>`,
+					`<table class="report-summary">`,
+					`<tr><td><a href="#command-1">1</a></td><td><a href="#command-1">Say `,
+					`<td><span class="status failed">failed</span></td><td>2</td><td>500ms</td>`,
+					`<section class="report-action passed" id="command-1">`,
+					`<h2><a href="#command-2">Command 2</a> <span class="status failed">failed</span></h2>`,
+					"<p>Say <code>hello</code></p>",
+				} {
+					if !strings.Contains(output, want) {
+						t.Errorf("missing %q in:\n%s", want, output)
+					}
+				}
+			},
+		},
+		{
+			name: "page reuses the markdown stylesheet and chroma theme",
+			results: []Result{
+				{Action: Action{Command: "ls -la"}},
+			},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "--bg: #1a1b26;") {
+					t.Error("missing the tokyonight page stylesheet")
+				}
+				if !strings.Contains(output, ".chroma") {
+					t.Error("missing the chroma stylesheet")
+				}
+				if !strings.Contains(output, `<pre class="chroma">`) {
+					t.Error("command should be highlighted by chroma")
+				}
+				if strings.Contains(output, "<script") {
+					t.Error("report page should load no scripts")
+				}
+			},
+		},
+		{
+			name: "output is collapsible and open only for failures",
+			results: []Result{
+				{Action: Action{Command: "echo ok"}, Output: "ok\n"},
+				{Action: Action{Command: "false"}, ExitCode: 1, Output: "a\nb\n"},
+				{Action: Action{Command: "flaky", AllowFailure: true}, ExitCode: 1},
+			},
+			verify: func(t *testing.T, output string) {
+				for _, want := range []string{
+					"<details class=\"report-output\">\n<summary>Output <span class=\"lines\">(1 line)</span></summary>\n<pre><code>ok</code></pre>",
+					"<details class=\"report-output\" open>\n<summary>Output <span class=\"lines\">(2 lines)</span></summary>\n<pre><code>a\nb</code></pre>",
+					"<summary>Output <span class=\"lines\">(empty)</span></summary>",
+					`<span class="status allowed">failed (allowed)</span>`,
+				} {
+					if !strings.Contains(output, want) {
+						t.Errorf("missing %q in:\n%s", want, output)
+					}
+				}
+			},
+		},
+		{
+			name: "stderr gets its own block",
+			results: []Result{
+				{Action: Action{Command: "cmd"}, Stdout: "out\n", Stderr: "err\n", Output: "out\nerr\n"},
+			},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "<summary>Stdout ") || !strings.Contains(output, "<summary>Stderr ") {
+					t.Errorf("missing separate Stdout and Stderr blocks in:\n%s", output)
+				}
+				if strings.Contains(output, "<summary>Output ") {
+					t.Error("should not render a combined Output block when stderr is present")
+				}
+			},
+		},
+		{
+			name: "output and labels are escaped",
+			results: []Result{
+				{
+					Action: Action{Command: "echo '<script>alert(1)</script>'"},
+					Output: "<script>alert(1)</script>\n",
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				if strings.Contains(output, "<script>alert(1)") {
+					t.Errorf("unescaped output in:\n%s", output)
+				}
+				if !strings.Contains(output, "<pre><code>&lt;script&gt;alert(1)&lt;/script&gt;</code></pre>") {
+					t.Error("missing escaped output")
+				}
+			},
+		},
+		{
+			name: "notes, tags, and failed assertions",
+			results: []Result{
+				{
+					Action: Action{
+						Command:    "sleep 5",
+						Tags:       []string{"slow", "smoke"},
+						Assertions: []Assertion{{Kind: OutputContains, Pattern: "done"}},
+					},
+					ExitCode:   -1,
+					TimedOut:   true,
+					Duration:   time.Second,
+					Assertions: []AssertionResult{{Assertion: Assertion{Kind: OutputContains, Pattern: "done"}}},
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				for _, want := range []string{
+					"<strong>Status Code</strong>: -1 (killed: timed out after 1s)",
+					"<strong>Tags</strong>: slow, smoke",
+					"<li><code>output-contains &#34;done&#34;</code></li>",
+				} {
+					if !strings.Contains(output, want) {
+						t.Errorf("missing %q in:\n%s", want, output)
+					}
+				}
+			},
+		},
+		{
+			name:    "resource usage and footer",
+			results: metadataResults(),
+			verify: func(t *testing.T, output string) {
+				for _, want := range []string{
+					"<strong>Resources</strong>: 2s user · 200ms system · 45.0 MiB max RSS",
+					"<strong>Directory</strong>: <code>/tmp</code>",
+					`<footer class="report-footer">`,
+					`<strong>Slowest</strong>: <a href="#command-2">Command 2</a> (3s)`,
+					"<strong>Environment</strong>: host build-01 · shell /bin/bash",
+				} {
+					if !strings.Contains(output, want) {
+						t.Errorf("missing %q in:\n%s", want, output)
+					}
+				}
+			},
+		},
+		{
+			name:    "empty results",
+			results: []Result{},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "0 commands") {
+					t.Error("missing totals line")
+				}
+				if strings.Contains(output, "<table") {
+					t.Error("should have no summary table")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := FormatHTML(tt.results)
+			if err != nil {
+				t.Fatalf("FormatHTML() returned unexpected error: %v", err)
+			}
+			tt.verify(t, output)
+		})
+	}
+}
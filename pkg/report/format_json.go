@@ -0,0 +1,236 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterFormatter(JSON, NewFormatter(".json", FormatJSON))
+	RegisterFormatter(JSONLines, NewFormatter(".jsonl", FormatJSONLines))
+}
+
+// jsonReport is the top-level JSON document for marshalling.
+type jsonReport struct {
+	Redactions int          `json:"redactions,omitempty"`
+	Actions    []jsonAction `json:"actions"`
+	Summary    *jsonSummary `json:"summary,omitempty"`
+}
+
+// jsonSummary summarizes the run: its total time, the index of its slowest
+// action, its resource totals, and where it ran.
+type jsonSummary struct {
+	TotalMS      int64  `json:"total_ms"`
+	Slowest      int    `json:"slowest"`
+	UserTimeMS   int64  `json:"user_time_ms,omitempty"`
+	SystemTimeMS int64  `json:"system_time_ms,omitempty"`
+	MaxRSS       int    `json:"max_rss_bytes,omitempty"`
+	Hostname     string `json:"hostname,omitempty"`
+	Shell        string `json:"shell,omitempty"`
+	Dir          string `json:"cwd,omitempty"`
+}
+
+// newJSONSummary summarizes results; it returns nil when no action ran.
+func newJSONSummary(results []Result) *jsonSummary {
+	s := summarize(results)
+	if s.Slowest < 0 {
+		return nil
+	}
+	return &jsonSummary{
+		TotalMS:      s.Total.Milliseconds(),
+		Slowest:      s.Slowest,
+		UserTimeMS:   s.UserTime.Milliseconds(),
+		SystemTimeMS: s.SystemTime.Milliseconds(),
+		MaxRSS:       s.MaxRSS,
+		Hostname:     s.Hostname,
+		Shell:        s.Shell,
+		Dir:          s.Dir,
+	}
+}
+
+// jsonAction represents a single action in the JSON and JSON Lines output.
+// Index is the action's zero-based position in the input, so streamed lines
+// (which arrive in completion order) can be matched back to their source.
+type jsonAction struct {
+	Index        int             `json:"index"`
+	Name         string          `json:"name,omitempty"`
+	Description  string          `json:"description"`
+	Command      string          `json:"command"`
+	Tags         []string        `json:"tags,omitempty"`
+	Needs        []string        `json:"needs,omitempty"`
+	ExitCode     int             `json:"exit_code"`
+	ExpectExit   int             `json:"expect_exit"`
+	Passed       bool            `json:"passed"`
+	Skipped      bool            `json:"skipped"`
+	BlockedBy    []string        `json:"blocked_by,omitempty"`
+	TimedOut     bool            `json:"timed_out"`
+	StartedAt    time.Time       `json:"started_at"`
+	FinishedAt   time.Time       `json:"finished_at"`
+	DurationMS   int64           `json:"duration_ms"`
+	UserTimeMS   int64           `json:"user_time_ms,omitempty"`
+	SystemTimeMS int64           `json:"system_time_ms,omitempty"`
+	MaxRSS       int             `json:"max_rss_bytes,omitempty"`
+	Dir          string          `json:"cwd,omitempty"`
+	Shell        string          `json:"shell,omitempty"`
+	Hostname     string          `json:"hostname,omitempty"`
+	Attempts     int             `json:"attempts"`
+	History      []jsonAttempt   `json:"attempt_history,omitempty"`
+	Assertions   []jsonAssertion `json:"assertions,omitempty"`
+	Redactions   int             `json:"redactions,omitempty"`
+	Truncated    bool            `json:"truncated"`
+	OutputSize   int             `json:"output_size,omitempty"`
+	OutputLines  int             `json:"output_lines,omitempty"`
+	Stdout       string          `json:"stdout"`
+	Stderr       string          `json:"stderr"`
+	Output       string          `json:"output"`
+}
+
+// jsonAssertion represents the outcome of one output assertion.
+type jsonAssertion struct {
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern"`
+	Passed  bool   `json:"passed"`
+}
+
+// jsonAttempt represents one attempt of an action that was retried.
+type jsonAttempt struct {
+	ExitCode   int    `json:"exit_code"`
+	TimedOut   bool   `json:"timed_out"`
+	DurationMS int64  `json:"duration_ms"`
+	Output     string `json:"output"`
+}
+
+// newJSONAttempts converts the attempts of a result into their JSON form; it
+// returns nil unless the action ran more than once.
+func newJSONAttempts(attempts []Attempt) []jsonAttempt {
+	if len(attempts) < 2 {
+		return nil
+	}
+	out := make([]jsonAttempt, len(attempts))
+	for i, a := range attempts {
+		out[i] = jsonAttempt{ExitCode: a.ExitCode, TimedOut: a.TimedOut, DurationMS: a.Duration.Milliseconds(), Output: a.Output}
+	}
+	return out
+}
+
+// newJSONAssertions converts assertion results into their JSON form.
+func newJSONAssertions(results []AssertionResult) []jsonAssertion {
+	if len(results) == 0 {
+		return nil
+	}
+	out := make([]jsonAssertion, len(results))
+	for i, ar := range results {
+		out[i] = jsonAssertion{Kind: string(ar.Assertion.Kind), Pattern: ar.Assertion.Pattern, Passed: ar.Passed}
+	}
+	return out
+}
+
+// newJSONAction converts the result at position index into its JSON form.
+func newJSONAction(index int, r Result) jsonAction {
+	return jsonAction{
+		Index:        index,
+		Name:         r.Action.Name,
+		Description:  r.Action.Description,
+		Command:      r.Action.Command,
+		Tags:         r.Action.Tags,
+		Needs:        r.Action.Needs,
+		ExitCode:     r.ExitCode,
+		ExpectExit:   r.Action.ExpectExit,
+		Passed:       r.Passed(),
+		Skipped:      r.Skipped,
+		BlockedBy:    r.BlockedBy,
+		TimedOut:     r.TimedOut,
+		StartedAt:    r.StartedAt,
+		FinishedAt:   r.FinishedAt,
+		DurationMS:   r.Duration.Milliseconds(),
+		UserTimeMS:   r.UserTime.Milliseconds(),
+		SystemTimeMS: r.SystemTime.Milliseconds(),
+		MaxRSS:       r.MaxRSS,
+		Dir:          r.Dir,
+		Shell:        r.Shell,
+		Hostname:     r.Hostname,
+		Attempts:     r.AttemptCount(),
+		History:      newJSONAttempts(r.Attempts),
+		Assertions:   newJSONAssertions(r.Assertions),
+		Redactions:   r.Redactions,
+		Truncated:    r.Truncated,
+		OutputSize:   r.OutputSize,
+		OutputLines:  r.OutputLines,
+		Stdout:       r.Stdout,
+		Stderr:       r.Stderr,
+		Output:       r.Output,
+	}
+}
+
+// FormatJSON formats the results as a single indented JSON document with an
+// "actions" array and a "summary" of the run.
+func FormatJSON(results []Result) (string, error) {
+	report := jsonReport{
+		Redactions: CountRedactions(results),
+		Actions:    make([]jsonAction, len(results)),
+		Summary:    newJSONSummary(results),
+	}
+
+	for i, r := range results {
+		report.Actions[i] = newJSONAction(i, r)
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("json marshal: %w", err)
+	}
+	return string(out), nil
+}
+
+// FormatJSONLine formats a single result as one compact JSON object without a
+// trailing newline. It is used to stream results as each action finishes.
+func FormatJSONLine(index int, r Result) (string, error) {
+	out, err := json.Marshal(newJSONAction(index, r))
+	if err != nil {
+		return "", fmt.Errorf("json marshal: %w", err)
+	}
+	return string(out), nil
+}
+
+// FormatJSONLinesSummary formats the run's summary as the closing JSON Lines
+// object, {"summary": {...}}. It returns "" when no action ran.
+func FormatJSONLinesSummary(results []Result) (string, error) {
+	summary := newJSONSummary(results)
+	if summary == nil {
+		return "", nil
+	}
+
+	out, err := json.Marshal(struct {
+		Summary *jsonSummary `json:"summary"`
+	}{summary})
+	if err != nil {
+		return "", fmt.Errorf("json marshal: %w", err)
+	}
+	return string(out), nil
+}
+
+// FormatJSONLines formats the results as JSON Lines: one object per action,
+// in input order, separated by newlines, then the summary line.
+func FormatJSONLines(results []Result) (string, error) {
+	lines := make([]string, 0, len(results)+1)
+
+	for i, r := range results {
+		line, err := FormatJSONLine(i, r)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+
+	summary, err := FormatJSONLinesSummary(results)
+	if err != nil {
+		return "", err
+	}
+	if summary != "" {
+		lines = append(lines, summary)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
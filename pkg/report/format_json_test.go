@@ -0,0 +1,251 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatJSON(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		results []Result
+		verify  func(t *testing.T, output string)
+	}{
+		{
+			name: "single result with all fields",
+			results: []Result{
+				{
+					Action:     Action{Description: "Say hello", Command: "echo hello"},
+					ExitCode:   0,
+					Output:     "hello\n",
+					Stdout:     "hello\n",
+					StartedAt:  start,
+					FinishedAt: start.Add(1500 * time.Millisecond),
+					Duration:   1500 * time.Millisecond,
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r jsonReport
+				if err := json.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid JSON: %v", err)
+				}
+				if len(r.Actions) != 1 {
+					t.Fatalf("expected 1 action, got %d", len(r.Actions))
+				}
+				a := r.Actions[0]
+				if a.Index != 0 {
+					t.Errorf("index = %d, want 0", a.Index)
+				}
+				if a.Description != "Say hello" {
+					t.Errorf("description = %q, want %q", a.Description, "Say hello")
+				}
+				if a.Command != "echo hello" {
+					t.Errorf("command = %q, want %q", a.Command, "echo hello")
+				}
+				if a.ExitCode != 0 {
+					t.Errorf("exit_code = %d, want 0", a.ExitCode)
+				}
+				if a.Output != "hello\n" || a.Stdout != "hello\n" {
+					t.Errorf("output = %q, stdout = %q, want %q", a.Output, a.Stdout, "hello\n")
+				}
+				if a.DurationMS != 1500 {
+					t.Errorf("duration_ms = %d, want 1500", a.DurationMS)
+				}
+				if !a.StartedAt.Equal(start) {
+					t.Errorf("started_at = %s, want %s", a.StartedAt, start)
+				}
+				if !a.FinishedAt.Equal(start.Add(1500 * time.Millisecond)) {
+					t.Errorf("finished_at = %s, want %s", a.FinishedAt, start.Add(1500*time.Millisecond))
+				}
+			},
+		},
+		{
+			name: "multiple results keep input order",
+			results: []Result{
+				{Action: Action{Command: "echo first"}, Output: "first"},
+				{Action: Action{Command: "false"}, ExitCode: 1},
+			},
+			verify: func(t *testing.T, output string) {
+				var r jsonReport
+				if err := json.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid JSON: %v", err)
+				}
+				if len(r.Actions) != 2 {
+					t.Fatalf("expected 2 actions, got %d", len(r.Actions))
+				}
+				if r.Actions[1].Index != 1 || r.Actions[1].ExitCode != 1 {
+					t.Errorf("second action = %+v, want index 1 and exit_code 1", r.Actions[1])
+				}
+			},
+		},
+		{
+			name: "timed-out action",
+			results: []Result{
+				{Action: Action{Command: "sleep 60"}, ExitCode: -1, TimedOut: true},
+			},
+			verify: func(t *testing.T, output string) {
+				var r jsonReport
+				if err := json.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid JSON: %v", err)
+				}
+				if !r.Actions[0].TimedOut {
+					t.Error("expected timed_out to be true")
+				}
+			},
+		},
+		{
+			name: "retried action lists its attempts",
+			results: []Result{
+				{Action: Action{Command: "true"}},
+				{
+					Action: Action{Command: "curl localhost"},
+					Output: "ok\n",
+					Attempts: []Attempt{
+						{ExitCode: 1, Output: "refused\n", Duration: 10 * time.Millisecond},
+						{ExitCode: 0, Output: "ok\n", Duration: 20 * time.Millisecond},
+					},
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r jsonReport
+				if err := json.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid JSON: %v", err)
+				}
+				if r.Actions[0].Attempts != 1 || r.Actions[0].History != nil {
+					t.Errorf("single run: attempts = %d, history = %v; want 1 and none", r.Actions[0].Attempts, r.Actions[0].History)
+				}
+				a := r.Actions[1]
+				if a.Attempts != 2 || len(a.History) != 2 {
+					t.Fatalf("attempts = %d with %d history entries, want 2 and 2", a.Attempts, len(a.History))
+				}
+				want := jsonAttempt{ExitCode: 1, DurationMS: 10, Output: "refused\n"}
+				if a.History[0] != want {
+					t.Errorf("history[0] = %+v, want %+v", a.History[0], want)
+				}
+			},
+		},
+		{
+			name:    "resource usage, environment, and summary",
+			results: metadataResults(),
+			verify: func(t *testing.T, output string) {
+				var r jsonReport
+				if err := json.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid JSON: %v", err)
+				}
+				a := r.Actions[1]
+				if a.UserTimeMS != 2000 || a.SystemTimeMS != 200 || a.MaxRSS != 45<<20 {
+					t.Errorf("resources = %d/%d ms, %d bytes; want 2000/200 ms, %d bytes", a.UserTimeMS, a.SystemTimeMS, a.MaxRSS, 45<<20)
+				}
+				if a.Dir != "/tmp" || a.Shell != "/bin/bash" || a.Hostname != "build-01" {
+					t.Errorf("environment = %q, %q, %q", a.Dir, a.Shell, a.Hostname)
+				}
+				want := jsonSummary{TotalMS: 4000, Slowest: 1, UserTimeMS: 2800, SystemTimeMS: 300, MaxRSS: 45 << 20, Hostname: "build-01", Shell: "/bin/bash"}
+				if r.Summary == nil || *r.Summary != want {
+					t.Errorf("summary = %+v, want %+v", r.Summary, want)
+				}
+			},
+		},
+		{
+			name:    "empty results",
+			results: []Result{},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, `"actions": []`) {
+					t.Errorf("expected an empty actions array, got %s", output)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := FormatJSON(tt.results)
+			if err != nil {
+				t.Fatalf("FormatJSON returned unexpected error: %v", err)
+			}
+			tt.verify(t, output)
+		})
+	}
+}
+
+func TestFormatJSONLines(t *testing.T) {
+	results := []Result{
+		{Action: Action{Description: "first", Command: "echo 1"}, Output: "1\n", Duration: time.Second},
+		{Action: Action{Description: "second", Command: "echo \"2\"\nexit 1"}, ExitCode: 1, Output: "2\n", Duration: 2 * time.Second},
+	}
+
+	output, err := FormatJSONLines(results)
+	if err != nil {
+		t.Fatalf("FormatJSONLines returned unexpected error: %v", err)
+	}
+
+	lines := strings.Split(output, "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %q", len(lines), output)
+	}
+
+	var last struct {
+		Summary *jsonSummary `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil {
+		t.Fatalf("summary line is not valid JSON: %v", err)
+	}
+	if last.Summary == nil || last.Summary.Slowest != 1 {
+		t.Errorf("summary line = %s, want the slowest action to be 1", lines[2])
+	}
+
+	for i, line := range lines[:2] {
+		var a jsonAction
+		if err := json.Unmarshal([]byte(line), &a); err != nil {
+			t.Fatalf("line %d is not valid JSON: %v", i, err)
+		}
+		if a.Index != i {
+			t.Errorf("line %d: index = %d, want %d", i, a.Index, i)
+		}
+		if a.Command != results[i].Action.Command {
+			t.Errorf("line %d: command = %q, want %q", i, a.Command, results[i].Action.Command)
+		}
+		if a.ExitCode != results[i].ExitCode {
+			t.Errorf("line %d: exit_code = %d, want %d", i, a.ExitCode, results[i].ExitCode)
+		}
+	}
+}
+
+func TestFormatJSONLinesSummary(t *testing.T) {
+	line, err := FormatJSONLinesSummary([]Result{{Action: Action{Command: "ls"}, Skipped: true}})
+	if err != nil {
+		t.Fatalf("FormatJSONLinesSummary returned unexpected error: %v", err)
+	}
+	if line != "" {
+		t.Errorf("summary of a run where nothing ran = %q, want empty", line)
+	}
+
+	output, err := FormatJSONLines(nil)
+	if err != nil {
+		t.Fatalf("FormatJSONLines returned unexpected error: %v", err)
+	}
+	if output != "" {
+		t.Errorf("FormatJSONLines(nil) = %q, want empty", output)
+	}
+}
+
+func TestFormatJSONLine(t *testing.T) {
+	line, err := FormatJSONLine(7, Result{Action: Action{Command: "ls"}})
+	if err != nil {
+		t.Fatalf("FormatJSONLine returned unexpected error: %v", err)
+	}
+	if strings.Contains(line, "\n") {
+		t.Errorf("line must not contain newlines: %q", line)
+	}
+
+	var a jsonAction
+	if err := json.Unmarshal([]byte(line), &a); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if a.Index != 7 {
+		t.Errorf("index = %d, want 7", a.Index)
+	}
+}
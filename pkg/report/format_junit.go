@@ -0,0 +1,253 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterFormatter(JUnit, NewFormatter(".xml", FormatJUnit))
+}
+
+// junitSuiteName is the <testsuite> name used for every report.
+const junitSuiteName = "scripts report"
+
+// junitTestSuites is the top-level JUnit XML structure for marshalling.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite groups every action of one report run.
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Hostname   string          `xml:"hostname,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase represents a single action.
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitFailure   `xml:"failure,omitempty"`
+	Skipped    *junitSkipped   `xml:"skipped,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+	SystemErr  string          `xml:"system-err,omitempty"`
+}
+
+// junitProperty is a name/value pair attached to a test case.
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitFailure marks a test case whose action did not exit with status 0.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSkipped marks a test case whose action was skipped because a need did
+// not pass.
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// junitSkippedFor returns the <skipped> element for a skipped result, or nil.
+func junitSkippedFor(r Result) *junitSkipped {
+	if !r.Skipped {
+		return nil
+	}
+	return &junitSkipped{Message: statusNote(r)}
+}
+
+// junitSeconds renders d as fractional seconds, the unit JUnit expects.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// junitCaseName names a test case after the action's description, falling
+// back to its position when the description is empty.
+func junitCaseName(i int, r Result) string {
+	if r.Action.Description != "" {
+		return r.Action.Description
+	}
+	return fmt.Sprintf("Command %d", i+1)
+}
+
+// junitFailureFor returns the <failure> element for a failed result, or nil
+// when the action passed or is allowed to fail.
+func junitFailureFor(r Result) *junitFailure {
+	output := strings.TrimRight(r.Output, "\n")
+
+	switch {
+	case !r.Failed():
+		return nil
+	case r.TimedOut:
+		return &junitFailure{Message: statusNote(r), Type: "timeout", Text: output}
+	case r.ExitCode != r.Action.ExpectExit:
+		message := fmt.Sprintf("exit status %d", r.ExitCode)
+		if r.Action.ExpectExit != 0 {
+			message += fmt.Sprintf(" (expected %d)", r.Action.ExpectExit)
+		}
+		return &junitFailure{Message: message, Type: "exit-code", Text: output}
+	default:
+		failed := r.FailedAssertions()
+		descriptions := make([]string, len(failed))
+		for i, a := range failed {
+			descriptions[i] = a.String()
+		}
+		message := "assertion failed: " + strings.Join(descriptions, ", ")
+		return &junitFailure{Message: message, Type: "assertion", Text: output}
+	}
+}
+
+// junitSuiteProperties lists the shell and working directory shared by the
+// actions, the slowest action, and the resource totals of the run, as test
+// suite properties; the host is the suite's hostname attribute.
+func junitSuiteProperties(s runSummary, results []Result) []junitProperty {
+	if s.Slowest < 0 {
+		return nil
+	}
+
+	var props []junitProperty
+	if s.Shell != "" {
+		props = append(props, junitProperty{Name: "shell", Value: s.Shell})
+	}
+	if s.Dir != "" {
+		props = append(props, junitProperty{Name: "cwd", Value: s.Dir})
+	}
+	props = append(props, junitProperty{Name: "slowest", Value: junitCaseName(s.Slowest, results[s.Slowest])})
+	return append(props, junitResourceProperties(s.UserTime, s.SystemTime, s.MaxRSS)...)
+}
+
+// junitResourceProperties lists CPU times in seconds and peak memory in bytes,
+// or nothing when none were reported.
+func junitResourceProperties(userTime, systemTime time.Duration, maxRSS int) []junitProperty {
+	if userTime == 0 && systemTime == 0 && maxRSS == 0 {
+		return nil
+	}
+	props := []junitProperty{
+		{Name: "user-time", Value: junitSeconds(userTime)},
+		{Name: "system-time", Value: junitSeconds(systemTime)},
+	}
+	if maxRSS > 0 {
+		props = append(props, junitProperty{Name: "max-rss", Value: fmt.Sprint(maxRSS)})
+	}
+	return props
+}
+
+// junitProperties lists the action's command, tags, and needs, the attempt
+// count of a retried action, the full output size of a truncated one, the
+// number of secrets redacted, its resource usage, and its working directory
+// when it differs from dir, the suite's, as test case properties.
+func junitProperties(r Result, dir string) []junitProperty {
+	props := []junitProperty{{Name: "command", Value: r.Action.Command}}
+	for _, t := range r.Action.Tags {
+		props = append(props, junitProperty{Name: "tag", Value: t})
+	}
+	for _, n := range r.Action.Needs {
+		props = append(props, junitProperty{Name: "needs", Value: n})
+	}
+	if n := r.AttemptCount(); n > 1 {
+		props = append(props, junitProperty{Name: "attempts", Value: fmt.Sprint(n)})
+	}
+	if r.Truncated {
+		props = append(props,
+			junitProperty{Name: "output-size", Value: fmt.Sprint(r.OutputSize)},
+			junitProperty{Name: "output-lines", Value: fmt.Sprint(r.OutputLines)})
+	}
+	if r.Redactions > 0 {
+		props = append(props, junitProperty{Name: "redactions", Value: fmt.Sprint(r.Redactions)})
+	}
+	props = append(props, junitResourceProperties(r.UserTime, r.SystemTime, r.MaxRSS)...)
+	if r.Dir != "" && r.Dir != dir {
+		props = append(props, junitProperty{Name: "cwd", Value: r.Dir})
+	}
+	return props
+}
+
+// FormatJUnit formats the results as a JUnit XML report so CI systems can show
+// each action as a test case. Unexpected exit codes, timeouts, and failed
+// output assertions become <failure> elements carrying the combined output,
+// unless the action allows failure; actions skipped for a need that did not
+// pass become <skipped> elements. Results from several hosts get one
+// <testsuite> per host.
+func FormatJUnit(results []Result) (string, error) {
+	report := junitTestSuites{
+		Tests: len(results),
+		Time:  junitSeconds(reportSpan(results)),
+	}
+
+	groups := groupByHost(results)
+	for _, group := range groups {
+		name := junitSuiteName
+		if len(groups) > 1 {
+			name = fmt.Sprintf("%s (%s)", junitSuiteName, group[0].Hostname)
+		}
+		suite := newJUnitSuite(name, group)
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+
+	out, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("xml marshal: %w", err)
+	}
+	return xml.Header + string(out), nil
+}
+
+// newJUnitSuite returns the test suite named name holding one test case per
+// result.
+func newJUnitSuite(name string, results []Result) junitTestSuite {
+	summary := summarize(results)
+	suite := junitTestSuite{
+		Name:       name,
+		Tests:      len(results),
+		Time:       junitSeconds(summary.Total),
+		Hostname:   summary.Hostname,
+		Properties: junitSuiteProperties(summary, results),
+		Cases:      make([]junitTestCase, len(results)),
+	}
+
+	for i, r := range results {
+		if i == 0 && !r.StartedAt.IsZero() {
+			suite.Timestamp = r.StartedAt.Format("2006-01-02T15:04:05")
+		}
+
+		tc := junitTestCase{
+			Name:       junitCaseName(i, r),
+			Classname:  name,
+			Time:       junitSeconds(r.Duration),
+			Properties: junitProperties(r, summary.Dir),
+			Failure:    junitFailureFor(r),
+			Skipped:    junitSkippedFor(r),
+			SystemOut:  strings.TrimRight(r.Stdout, "\n"),
+			SystemErr:  strings.TrimRight(r.Stderr, "\n"),
+		}
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+		if tc.Skipped != nil {
+			suite.Skipped++
+		}
+		suite.Cases[i] = tc
+	}
+
+	return suite
+}
@@ -0,0 +1,292 @@
+package report
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatJUnit(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		results []Result
+		verify  func(t *testing.T, output string)
+	}{
+		{
+			name: "passing and failing actions",
+			results: []Result{
+				{
+					Action:     Action{Description: "Say hello", Command: "echo hello"},
+					ExitCode:   0,
+					Output:     "hello\n",
+					Stdout:     "hello\n",
+					StartedAt:  start,
+					FinishedAt: start.Add(time.Second),
+					Duration:   time.Second,
+				},
+				{
+					Action:     Action{Description: "Check disk", Command: "df -h /missing"},
+					ExitCode:   1,
+					Output:     "df: /missing: No such file\n",
+					Stderr:     "df: /missing: No such file\n",
+					StartedAt:  start.Add(time.Second),
+					FinishedAt: start.Add(1500 * time.Millisecond),
+					Duration:   500 * time.Millisecond,
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				if r.Tests != 2 || r.Failures != 1 {
+					t.Errorf("testsuites tests=%d failures=%d, want 2 and 1", r.Tests, r.Failures)
+				}
+				if len(r.Suites) != 1 {
+					t.Fatalf("expected 1 testsuite, got %d", len(r.Suites))
+				}
+				s := r.Suites[0]
+				if s.Tests != 2 || s.Failures != 1 {
+					t.Errorf("testsuite tests=%d failures=%d, want 2 and 1", s.Tests, s.Failures)
+				}
+				if s.Time != "1.500" {
+					t.Errorf("testsuite time = %q, want %q", s.Time, "1.500")
+				}
+				if s.Timestamp != "2024-05-01T12:00:00" {
+					t.Errorf("testsuite timestamp = %q, want %q", s.Timestamp, "2024-05-01T12:00:00")
+				}
+
+				pass := s.Cases[0]
+				if pass.Name != "Say hello" {
+					t.Errorf("testcase name = %q, want %q", pass.Name, "Say hello")
+				}
+				if pass.Failure != nil {
+					t.Error("passing action must not have a failure")
+				}
+				if len(pass.Properties) != 1 || pass.Properties[0].Name != "command" || pass.Properties[0].Value != "echo hello" {
+					t.Errorf("testcase properties = %+v, want command=echo hello", pass.Properties)
+				}
+				if pass.SystemOut != "hello" {
+					t.Errorf("system-out = %q, want %q", pass.SystemOut, "hello")
+				}
+				if pass.Time != "1.000" {
+					t.Errorf("testcase time = %q, want %q", pass.Time, "1.000")
+				}
+
+				fail := s.Cases[1]
+				if fail.Failure == nil {
+					t.Fatal("failing action must have a failure")
+				}
+				if fail.Failure.Message != "exit status 1" {
+					t.Errorf("failure message = %q, want %q", fail.Failure.Message, "exit status 1")
+				}
+				if fail.Failure.Text != "df: /missing: No such file" {
+					t.Errorf("failure text = %q, want the combined output", fail.Failure.Text)
+				}
+				if fail.SystemErr != "df: /missing: No such file" {
+					t.Errorf("system-err = %q, want the stderr stream", fail.SystemErr)
+				}
+			},
+		},
+		{
+			name: "timed-out action is a timeout failure",
+			results: []Result{
+				{Action: Action{Command: "sleep 60"}, ExitCode: -1, TimedOut: true, Duration: 5 * time.Second},
+			},
+			verify: func(t *testing.T, output string) {
+				var r junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				tc := r.Suites[0].Cases[0]
+				if tc.Name != "Command 1" {
+					t.Errorf("testcase name = %q, want fallback %q", tc.Name, "Command 1")
+				}
+				if tc.Failure == nil || tc.Failure.Type != "timeout" {
+					t.Fatalf("failure = %+v, want type timeout", tc.Failure)
+				}
+				if !strings.Contains(tc.Failure.Message, "timed out after 5s") {
+					t.Errorf("failure message = %q", tc.Failure.Message)
+				}
+			},
+		},
+		{
+			name: "failed assertion is an assertion failure",
+			results: []Result{
+				{
+					Action: Action{Command: "echo starting"},
+					Output: "starting\n",
+					Assertions: []AssertionResult{
+						{Assertion: Assertion{Kind: OutputContains, Pattern: "ready"}, Passed: false},
+					},
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				f := r.Suites[0].Cases[0].Failure
+				if f == nil || f.Type != "assertion" {
+					t.Fatalf("failure = %+v, want type assertion", f)
+				}
+				if f.Message != `assertion failed: output-contains "ready"` {
+					t.Errorf("failure message = %q", f.Message)
+				}
+			},
+		},
+		{
+			name: "retried action records its attempt count",
+			results: []Result{
+				{
+					Action: Action{Command: "curl localhost"},
+					Attempts: []Attempt{
+						{ExitCode: 1, Output: "refused\n", Duration: 10 * time.Millisecond},
+						{ExitCode: 0, Output: "ok\n", Duration: 20 * time.Millisecond},
+					},
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				props := r.Suites[0].Cases[0].Properties
+				last := props[len(props)-1]
+				if last.Name != "attempts" || last.Value != "2" {
+					t.Errorf("last property = %+v, want attempts=2", last)
+				}
+			},
+		},
+		{
+			name: "skipped action is a skipped test case",
+			results: []Result{
+				{Action: Action{Name: "build", Command: "make"}, ExitCode: 2, Output: "error\n"},
+				{Action: Action{Command: "make test", Needs: []string{"build"}}, ExitCode: -1, Skipped: true, BlockedBy: []string{"build"}},
+			},
+			verify: func(t *testing.T, output string) {
+				var r junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				s := r.Suites[0]
+				if s.Failures != 1 || s.Skipped != 1 {
+					t.Errorf("testsuite failures=%d skipped=%d, want 1 and 1", s.Failures, s.Skipped)
+				}
+				tc := s.Cases[1]
+				if tc.Failure != nil {
+					t.Errorf("skipped action has a failure: %+v", tc.Failure)
+				}
+				if tc.Skipped == nil || tc.Skipped.Message != "skipped: needs build" {
+					t.Errorf("skipped = %+v, want message %q", tc.Skipped, "skipped: needs build")
+				}
+				if !strings.Contains(output, `<property name="needs" value="build"></property>`) {
+					t.Errorf("expected a needs property in output:\n%s", output)
+				}
+			},
+		},
+		{
+			name: "XML escaping of special characters",
+			results: []Result{
+				{Action: Action{Description: "a < b & c", Command: `test "1" < 2`}, ExitCode: 1, Output: "<bad>&"},
+			},
+			verify: func(t *testing.T, output string) {
+				var r junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				tc := r.Suites[0].Cases[0]
+				if tc.Name != "a < b & c" {
+					t.Errorf("name not properly round-tripped: %q", tc.Name)
+				}
+				if tc.Properties[0].Value != `test "1" < 2` {
+					t.Errorf("command not properly round-tripped: %q", tc.Properties[0].Value)
+				}
+				if tc.Failure.Text != "<bad>&" {
+					t.Errorf("failure text not properly round-tripped: %q", tc.Failure.Text)
+				}
+			},
+		},
+		{
+			name:    "host, resources, and working directories",
+			results: metadataResults(),
+			verify: func(t *testing.T, output string) {
+				var r junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				s := r.Suites[0]
+				if s.Hostname != "build-01" {
+					t.Errorf("testsuite hostname = %q, want %q", s.Hostname, "build-01")
+				}
+				for _, want := range []string{
+					`<property name="shell" value="/bin/bash"></property>`,
+					`<property name="slowest" value="Command 2"></property>`,
+					`<property name="user-time" value="2.000"></property>`,
+					`<property name="max-rss" value="47185920"></property>`,
+					`<property name="cwd" value="/tmp"></property>`,
+				} {
+					if !strings.Contains(output, want) {
+						t.Errorf("missing %q in:\n%s", want, output)
+					}
+				}
+			},
+		},
+		{
+			name: "one suite per host",
+			results: []Result{
+				{Action: Action{Command: "uptime"}, Hostname: "web-1"},
+				{Action: Action{Command: "uptime"}, Hostname: "web-2", ExitCode: 1},
+				{Action: Action{Command: "df"}, Hostname: "web-1"},
+			},
+			verify: func(t *testing.T, output string) {
+				var r junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				if len(r.Suites) != 2 || r.Tests != 3 || r.Failures != 1 {
+					t.Fatalf("got %d suites, tests=%d failures=%d; want 2, 3, and 1", len(r.Suites), r.Tests, r.Failures)
+				}
+				for i, want := range []struct {
+					name  string
+					host  string
+					tests int
+				}{{"scripts report (web-1)", "web-1", 2}, {"scripts report (web-2)", "web-2", 1}} {
+					s := r.Suites[i]
+					if s.Name != want.name || s.Hostname != want.host || s.Tests != want.tests {
+						t.Errorf("suite[%d] = %q on %q with %d tests, want %q on %q with %d", i, s.Name, s.Hostname, s.Tests, want.name, want.host, want.tests)
+					}
+				}
+			},
+		},
+		{
+			name:    "empty results",
+			results: []Result{},
+			verify: func(t *testing.T, output string) {
+				if !strings.HasPrefix(output, "<?xml") {
+					t.Error("expected an XML declaration")
+				}
+				var r junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				if r.Tests != 0 || len(r.Suites) != 1 {
+					t.Errorf("got tests=%d suites=%d, want an empty suite", r.Tests, len(r.Suites))
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := FormatJUnit(tt.results)
+			if err != nil {
+				t.Fatalf("FormatJUnit returned unexpected error: %v", err)
+			}
+			tt.verify(t, output)
+		})
+	}
+}
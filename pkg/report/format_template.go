@@ -0,0 +1,97 @@
+package report
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templatePrefix starts a template format, "template:PATH", whose reports
+// are rendered by the Go text/template in the file at PATH.
+const templatePrefix = "template:"
+
+// templatePath returns the template file of a template format.
+func (f Format) templatePath() (string, bool) {
+	path, ok := strings.CutPrefix(string(f), templatePrefix)
+	return path, ok && path != ""
+}
+
+// templateFuncs are the functions report templates can call, besides the
+// text/template builtins and the methods of Result.
+var templateFuncs = template.FuncMap{
+	// Per result.
+	"label":    func(r Result) string { return actionLabel(r.Action) },
+	"verdict":  verdict,
+	"note":     statusNote,
+	"duration": formatDuration,
+	"bytes":    formatBytes,
+
+	// Per report.
+	"passed": func(results []Result) int {
+		n := 0
+		for _, r := range results {
+			if r.Passed() {
+				n++
+			}
+		}
+		return n
+	},
+	"failed":  CountFailed,
+	"skipped": CountSkipped,
+	"total":   func(results []Result) time.Duration { return reportSpan(results) },
+
+	// Text.
+	"json": func(v any) (string, error) {
+		out, err := json.Marshal(v)
+		return string(out), err
+	},
+	"trim": strings.TrimSpace,
+	"join": strings.Join,
+}
+
+// templateFormatter renders reports with a user's template.
+type templateFormatter struct {
+	tmpl      *template.Template
+	extension string
+}
+
+// NewTemplateFormatter parses text, the template read from the file at path,
+// into a Formatter. The template executes with the []Result of the run as
+// its data and can call templateFuncs. Its reports are named after path
+// without a ".tmpl" suffix, e.g. ".txt" for "slack.txt.tmpl", or ".txt"
+// when that leaves no extension.
+func NewTemplateFormatter(path, text string) (Formatter, error) {
+	name := filepath.Base(path)
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return templateFormatter{tmpl: tmpl, extension: templateExtension(path)}, nil
+}
+
+// templateExtension returns the extension of reports rendered by the
+// template at path.
+func templateExtension(path string) string {
+	if ext := filepath.Ext(strings.TrimSuffix(filepath.Base(path), ".tmpl")); ext != "" {
+		return ext
+	}
+	return ".txt"
+}
+
+// Format executes the template. A final newline is dropped, since reports
+// are printed and written with one.
+func (f templateFormatter) Format(results []Result) (string, error) {
+	var b strings.Builder
+	if err := f.tmpl.Execute(&b, results); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func (f templateFormatter) Extension() string {
+	return f.extension
+}
@@ -0,0 +1,79 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatTemplate(t *testing.T) {
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	results := []Result{
+		{
+			Action:     Action{Description: "Build", Command: "make"},
+			Output:     "ok\n",
+			StartedAt:  start,
+			FinishedAt: start.Add(1500 * time.Millisecond),
+			Duration:   1500 * time.Millisecond,
+		},
+		{Action: Action{Command: "make test"}, ExitCode: 2, TimedOut: true, Output: "FAIL"},
+		{Action: Action{Command: "deploy"}, ExitCode: -1, Skipped: true, BlockedBy: []string{"test"}},
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "slack summary",
+			text: "{{ passed . }} passed, {{ failed . }} failed, {{ skipped . }} skipped in {{ duration (total .) }}\n" +
+				"{{ range . }}{{ if .Failed }}:x:{{ else }}:white_check_mark:{{ end }} {{ label . }} ({{ verdict . }})\n{{ end }}",
+			want: "1 passed, 1 failed, 1 skipped in 1.5s\n" +
+				":white_check_mark: Build (passed)\n:x: make test (failed)\n:white_check_mark: deploy (skipped)",
+		},
+		{
+			name: "prompt envelope",
+			text: `{"failures": [{{ range $i, $r := . }}{{ if $r.Failed }}{"command": {{ json $r.Action.Command }}, "output": {{ json (trim $r.Output) }}, "note": {{ json (note $r) }}}{{ end }}{{ end }}]}`,
+			want: `{"failures": [{"command": "make test", "output": "FAIL", "note": "killed: timed out after 0s"}]}`,
+		},
+		{
+			name:    "execution error",
+			text:    "{{ index . 5 }}",
+			wantErr: "index out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewTemplateFormatter("test.tmpl", tt.text)
+			if err != nil {
+				t.Fatalf("NewTemplateFormatter() returned unexpected error: %v", err)
+			}
+
+			got, err := f.Format(results)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Format() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Format() returned unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTemplateFormatterErrors(t *testing.T) {
+	if _, err := NewTemplateFormatter("bad.tmpl", "{{ .Missing "); err == nil || !strings.Contains(err.Error(), "bad.tmpl") {
+		t.Errorf("NewTemplateFormatter() error = %v, want a parse error naming the template", err)
+	}
+	if _, err := NewTemplateFormatter("bad.tmpl", "{{ nosuchfunc . }}"); err == nil || !strings.Contains(err.Error(), `function "nosuchfunc" not defined`) {
+		t.Errorf("NewTemplateFormatter() error = %v, want an undefined function error", err)
+	}
+}
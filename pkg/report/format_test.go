@@ -2,8 +2,10 @@ package report
 
 import (
 	"encoding/xml"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestFormatXML(t *testing.T) {
@@ -125,6 +127,160 @@ func TestFormatXML(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "timed-out action is marked as killed",
+			results: []Result{
+				{
+					Action:   Action{Description: "hang", Command: "sleep 60"},
+					ExitCode: -1,
+					Output:   "partial",
+					TimedOut: true,
+					Duration: 5 * time.Second,
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r xmlReport
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				a := r.Actions[0]
+				if !a.TimedOut {
+					t.Error("expected <timed-out>true</timed-out>")
+				}
+				if a.Status != -1 {
+					t.Errorf("status = %d, want -1", a.Status)
+				}
+				if a.Duration != "5s" {
+					t.Errorf("duration = %q, want %q", a.Duration, "5s")
+				}
+			},
+		},
+		{
+			name: "exited action has no timed-out element",
+			results: []Result{
+				{
+					Action:   Action{Command: "true"},
+					ExitCode: 0,
+					Duration: 1500 * time.Millisecond,
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				if strings.Contains(output, "timed-out") {
+					t.Error("unexpected timed-out element for a normal exit")
+				}
+				if !strings.Contains(output, "<duration>1.5s</duration>") {
+					t.Error("expected <duration>1.5s</duration>")
+				}
+			},
+		},
+		{
+			name: "stdout and stderr are reported separately",
+			results: []Result{
+				{
+					Action:   Action{Command: "echo data; echo warn >&2"},
+					ExitCode: 0,
+					Output:   "data\nwarn\n",
+					Stdout:   "data\n",
+					Stderr:   "warn\n",
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r xmlReport
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				a := r.Actions[0]
+				if a.Stdout != "data" {
+					t.Errorf("stdout = %q, want %q", a.Stdout, "data")
+				}
+				if a.Stderr != "warn" {
+					t.Errorf("stderr = %q, want %q", a.Stderr, "warn")
+				}
+				if a.Output != "data\nwarn" {
+					t.Errorf("output = %q, want the combined streams", a.Output)
+				}
+			},
+		},
+		{
+			name: "directive options are reported",
+			results: []Result{
+				{
+					Action:   Action{Command: "grep -q x f", ExpectExit: 1, AllowFailure: true, Tags: []string{"smoke", "db"}},
+					ExitCode: 0,
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r xmlReport
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				a := r.Actions[0]
+				if len(a.Tags) != 2 || a.Tags[0] != "smoke" || a.Tags[1] != "db" {
+					t.Errorf("tags = %v, want [smoke db]", a.Tags)
+				}
+				if a.ExpectedStatus != 1 {
+					t.Errorf("expected-status = %d, want 1", a.ExpectedStatus)
+				}
+				if !a.AllowFailure {
+					t.Error("expected <allow-failure>true</allow-failure>")
+				}
+			},
+		},
+		{
+			name: "assertion outcomes are listed",
+			results: []Result{
+				{
+					Action: Action{Command: "echo starting"},
+					Assertions: []AssertionResult{
+						{Assertion: Assertion{Kind: OutputContains, Pattern: "start"}, Passed: true},
+						{Assertion: Assertion{Kind: OutputMatches, Pattern: "<ready>"}, Passed: false},
+					},
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r xmlReport
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				got := r.Actions[0].Assertions
+				if len(got) != 2 {
+					t.Fatalf("expected 2 assertions, got %d", len(got))
+				}
+				if got[0].Kind != "output-contains" || !got[0].Passed || got[0].Pattern != "start" {
+					t.Errorf("assertion[0] = %+v", got[0])
+				}
+				if got[1].Kind != "output-match" || got[1].Passed || got[1].Pattern != "<ready>" {
+					t.Errorf("assertion[1] = %+v", got[1])
+				}
+			},
+		},
+		{
+			name: "retried action lists its attempts",
+			results: []Result{
+				{
+					Action: Action{Command: "curl localhost"},
+					Output: "ok\n",
+					Attempts: []Attempt{
+						{ExitCode: 1, Output: "refused\n", Duration: 10 * time.Millisecond},
+						{ExitCode: 0, Output: "ok\n", Duration: 20 * time.Millisecond},
+					},
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				var r xmlReport
+				if err := xml.Unmarshal([]byte(output), &r); err != nil {
+					t.Fatalf("invalid XML: %v", err)
+				}
+				a := r.Actions[0].Attempts
+				if a == nil || a.Count != 2 || len(a.Attempts) != 2 {
+					t.Fatalf("attempts = %+v, want a count of 2 and both attempts", a)
+				}
+				want := xmlAttempt{Status: 1, Duration: "10ms", Output: "refused"}
+				if a.Attempts[0] != want {
+					t.Errorf("attempt[0] = %+v, want %+v", a.Attempts[0], want)
+				}
+			},
+		},
 		{
 			name: "empty results",
 			results: []Result{},
@@ -174,6 +330,87 @@ func TestFormatXML(t *testing.T) {
 	}
 }
 
+func TestParseXML(t *testing.T) {
+	results := []Result{
+		{
+			Action: Action{
+				Description:  "Check <config>",
+				Command:      "grep -q x f && echo \"ok\"",
+				Timeout:      30 * time.Second,
+				Retries:      2,
+				ExpectExit:   1,
+				AllowFailure: true,
+				Tags:         []string{"smoke", "db"},
+				Assertions:   []Assertion{{Kind: OutputContains, Pattern: "ok"}},
+			},
+			ExitCode:   1,
+			Output:     "ok\nwarn",
+			Stdout:     "ok",
+			Stderr:     "warn",
+			Duration:   1500 * time.Millisecond,
+			Assertions: []AssertionResult{{Assertion: Assertion{Kind: OutputContains, Pattern: "ok"}, Passed: true}},
+			Attempts: []Attempt{
+				{ExitCode: 2, Output: "retry", Duration: 10 * time.Millisecond},
+				{ExitCode: 1, Output: "ok\nwarn", Duration: 1500 * time.Millisecond},
+			},
+		},
+		{
+			Action:   Action{Name: "wait", Command: "sleep 60"},
+			ExitCode: -1,
+			TimedOut: true,
+			Duration: 5 * time.Second,
+		},
+		metadataResults()[1],
+		{
+			Action:      Action{Command: "yes | head -n 100000"},
+			Output:      "y\n[… truncated 195.3 KiB, 99998 lines …]\ny",
+			Truncated:   true,
+			OutputSize:  200000,
+			OutputLines: 100000,
+		},
+		{
+			Action:    Action{Command: "make deploy", Needs: []string{"wait"}},
+			ExitCode:  -1,
+			Skipped:   true,
+			BlockedBy: []string{"wait"},
+		},
+	}
+
+	data, err := FormatXML(results)
+	if err != nil {
+		t.Fatalf("FormatXML() returned unexpected error: %v", err)
+	}
+
+	got, err := ParseXML([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseXML() returned unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, results) {
+		t.Errorf("ParseXML(FormatXML(results)) =\n%#v\nwant\n%#v", got, results)
+	}
+}
+
+func TestParseXMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "not XML", data: "# Report", want: "xml unmarshal"},
+		{name: "bad duration", data: "<report><action><duration>soon</duration></action></report>", want: `action 1: invalid duration "soon"`},
+		{name: "bad start time", data: "<report><action><started>noon</started></action></report>", want: `action 1: invalid start time "noon"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseXML([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseXML() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestFormatMarkdown(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -272,6 +509,129 @@ func TestFormatMarkdown(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "timed-out action is marked as killed",
+			results: []Result{
+				{
+					Action:   Action{Command: "sleep 60"},
+					ExitCode: -1,
+					TimedOut: true,
+					Duration: 5 * time.Second,
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "**Status Code**: -1 (killed: timed out after 5s)") {
+					t.Error("missing killed status note")
+				}
+				if !strings.Contains(output, "**Duration**: 5s") {
+					t.Error("missing duration")
+				}
+			},
+		},
+		{
+			name: "stderr splits output into separate blocks",
+			results: []Result{
+				{
+					Action:   Action{Command: "echo data; echo warn >&2"},
+					ExitCode: 0,
+					Output:   "data\nwarn\n",
+					Stdout:   "data\n",
+					Stderr:   "warn\n",
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "**Stdout**:\n\n```\ndata\n```") {
+					t.Error("missing stdout block")
+				}
+				if !strings.Contains(output, "**Stderr**:\n\n```\nwarn\n```") {
+					t.Error("missing stderr block")
+				}
+				if strings.Contains(output, "**Output**:") {
+					t.Error("combined output block should be replaced by the stream blocks")
+				}
+			},
+		},
+		{
+			name: "unexpected exit code and allowed failure are noted",
+			results: []Result{
+				{
+					Action:   Action{Command: "true", ExpectExit: 1, AllowFailure: true, Tags: []string{"smoke"}},
+					ExitCode: 0,
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "**Status Code**: 0 (expected 1; failure allowed)") {
+					t.Errorf("missing status notes in:\n%s", output)
+				}
+				if !strings.Contains(output, "**Tags**: smoke") {
+					t.Error("missing tags")
+				}
+			},
+		},
+		{
+			name: "retried action shows its attempt count",
+			results: []Result{
+				{
+					Action:   Action{Command: "curl localhost"},
+					ExitCode: 1,
+					Attempts: []Attempt{{ExitCode: 1}, {ExitCode: 1}, {ExitCode: 1}},
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "**Status Code**: 1 (3 attempts)") {
+					t.Errorf("missing attempt count in:\n%s", output)
+				}
+			},
+		},
+		{
+			name: "failed assertions are listed",
+			results: []Result{
+				{
+					Action: Action{Command: "echo starting"},
+					Assertions: []AssertionResult{
+						{Assertion: Assertion{Kind: OutputContains, Pattern: "start"}, Passed: true},
+						{Assertion: Assertion{Kind: OutputContains, Pattern: "ready"}, Passed: false},
+					},
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "**Failed Assertions**:\n\n- output-contains \"ready\"\n") {
+					t.Errorf("missing failed assertion list in:\n%s", output)
+				}
+				if strings.Contains(output, `output-contains "start"`) {
+					t.Error("passing assertions should not be listed")
+				}
+			},
+		},
+		{
+			name:    "resource usage and summary footer",
+			results: metadataResults(),
+			verify: func(t *testing.T, output string) {
+				for _, want := range []string{
+					"**Resources**: 2s user · 200ms system · 45.0 MiB max RSS",
+					"**Directory**: /tmp",
+					"\n\n---\n\n**Total**: 4s · **Slowest**: Command 2 (3s)\n",
+					"**Resources**: 2.8s user · 300ms system · 45.0 MiB max RSS",
+					"**Environment**: host build-01 · shell /bin/bash\n",
+				} {
+					if !strings.Contains(output, want) {
+						t.Errorf("missing %q in:\n%s", want, output)
+					}
+				}
+			},
+		},
+		{
+			name: "host is shown when results span several",
+			results: []Result{
+				{Action: Action{Command: "uptime"}, Hostname: "web-1"},
+				{Action: Action{Command: "uptime"}, Hostname: "web-2"},
+			},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "**Host**: web-1") || !strings.Contains(output, "**Host**: web-2") {
+					t.Errorf("expected a host line per command:\n%s", output)
+				}
+			},
+		},
 		{
 			name: "empty results",
 			results: []Result{},
@@ -301,6 +661,20 @@ func TestFormatMarkdown(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "output containing a fence gets a longer fence",
+			results: []Result{
+				{
+					Action: Action{Command: "cat README.md"},
+					Output: "```sh\nls\n```",
+				},
+			},
+			verify: func(t *testing.T, output string) {
+				if !strings.Contains(output, "````\n```sh\nls\n```\n````") {
+					t.Errorf("output fence should outlast the backticks inside it:\n%s", output)
+				}
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -345,6 +719,62 @@ func TestFormatReport(t *testing.T) {
 		}
 	})
 
+	t.Run("dispatches to JSON", func(t *testing.T) {
+		got, err := FormatReport(results, JSON)
+		if err != nil {
+			t.Fatalf("FormatReport(JSON) returned unexpected error: %v", err)
+		}
+		want, err := FormatJSON(results)
+		if err != nil {
+			t.Fatalf("FormatJSON returned unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("FormatReport(JSON) differs from FormatJSON:\ngot:  %q\nwant: %q", got, want)
+		}
+	})
+
+	t.Run("dispatches to JSON Lines", func(t *testing.T) {
+		got, err := FormatReport(results, JSONLines)
+		if err != nil {
+			t.Fatalf("FormatReport(JSONLines) returned unexpected error: %v", err)
+		}
+		want, err := FormatJSONLines(results)
+		if err != nil {
+			t.Fatalf("FormatJSONLines returned unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("FormatReport(JSONLines) differs from FormatJSONLines:\ngot:  %q\nwant: %q", got, want)
+		}
+	})
+
+	t.Run("dispatches to JUnit", func(t *testing.T) {
+		got, err := FormatReport(results, JUnit)
+		if err != nil {
+			t.Fatalf("FormatReport(JUnit) returned unexpected error: %v", err)
+		}
+		want, err := FormatJUnit(results)
+		if err != nil {
+			t.Fatalf("FormatJUnit returned unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("FormatReport(JUnit) differs from FormatJUnit:\ngot:  %q\nwant: %q", got, want)
+		}
+	})
+
+	t.Run("dispatches to HTML", func(t *testing.T) {
+		got, err := FormatReport(results, HTML)
+		if err != nil {
+			t.Fatalf("FormatReport(HTML) returned unexpected error: %v", err)
+		}
+		want, err := FormatHTML(results)
+		if err != nil {
+			t.Fatalf("FormatHTML returned unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("FormatReport(HTML) differs from FormatHTML:\ngot:  %q\nwant: %q", got, want)
+		}
+	})
+
 	t.Run("returns error for unknown format", func(t *testing.T) {
 		_, err := FormatReport(results, Format("unknown"))
 		if err == nil {
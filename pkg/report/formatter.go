@@ -0,0 +1,81 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Formatter renders the results of a run as a whole report.
+type Formatter interface {
+	// Format renders results.
+	Format(results []Result) (string, error)
+	// Extension is the file name extension of its reports, e.g. ".md".
+	Extension() string
+}
+
+// formatterFunc is a Formatter made of a function and an extension.
+type formatterFunc struct {
+	format    func(results []Result) (string, error)
+	extension string
+}
+
+func (f formatterFunc) Format(results []Result) (string, error) { return f.format(results) }
+func (f formatterFunc) Extension() string                       { return f.extension }
+
+// NewFormatter returns a Formatter that renders reports with format and
+// names their files with extension.
+func NewFormatter(extension string, format func(results []Result) (string, error)) Formatter {
+	return formatterFunc{format: format, extension: extension}
+}
+
+// formatters maps each registered Format to its Formatter, and formats lists
+// them in the order they were registered, for error messages.
+var (
+	formatters = map[Format]Formatter{}
+	formats    []Format
+)
+
+// RegisterFormatter makes f available as format, so ParseFormat accepts it
+// and FormatReport renders with it. A format is added in a file of its own
+// that registers it from an init function. It panics when format is already
+// registered or holds a colon, which template formats use.
+func RegisterFormatter(format Format, f Formatter) {
+	if format == "" || strings.Contains(string(format), ":") {
+		panic(fmt.Sprintf("report: invalid format name %q", format))
+	}
+	if _, ok := formatters[format]; ok {
+		panic(fmt.Sprintf("report: format %q registered twice", format))
+	}
+	formatters[format] = f
+	formats = append(formats, format)
+}
+
+// LookupFormatter returns the Formatter of format. For a template format it
+// reads and parses the template file, so a broken template is reported
+// before anything runs.
+func LookupFormatter(format Format) (Formatter, error) {
+	if f, ok := formatters[format]; ok {
+		return f, nil
+	}
+
+	if path, ok := format.templatePath(); ok {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading the template: %w", err)
+		}
+		return NewTemplateFormatter(path, string(text))
+	}
+
+	return nil, unsupportedFormat(string(format))
+}
+
+// unsupportedFormat is the error for a format name nothing is registered as.
+func unsupportedFormat(s string) error {
+	quoted := make([]string, len(formats)+1)
+	for i, f := range formats {
+		quoted[i] = fmt.Sprintf("%q", f)
+	}
+	quoted[len(formats)] = fmt.Sprintf("%q", templatePrefix+"PATH")
+	return fmt.Errorf("unsupported format: %q (expected one of %s)", s, strings.Join(quoted, ", "))
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegisterFormatter(t *testing.T) {
+	const csv Format = "test-csv"
+	RegisterFormatter(csv, NewFormatter(".csv", func(results []Result) (string, error) {
+		var lines []string
+		for _, r := range results {
+			lines = append(lines, r.Action.Command+","+verdict(r))
+		}
+		return strings.Join(lines, "\n"), nil
+	}))
+
+	if f, err := ParseFormat("test-csv"); err != nil || f != csv {
+		t.Fatalf("ParseFormat() = %q, %v; want the registered format", f, err)
+	}
+	if got := csv.Extension(); got != ".csv" {
+		t.Errorf("Extension() = %q, want %q", got, ".csv")
+	}
+
+	got, err := FormatReport([]Result{{Action: Action{Command: "true"}}}, csv)
+	if err != nil || got != "true,passed" {
+		t.Errorf("FormatReport() = %q, %v; want %q", got, err, "true,passed")
+	}
+
+	for _, name := range []Format{csv, "", "a:b"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterFormatter(%q) did not panic", name)
+				}
+			}()
+			RegisterFormatter(name, NewFormatter(".txt", FormatXML))
+		}()
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"xml", "md", "json", "jsonl", "junit", "html", "template:./slack.tmpl"} {
+		if _, err := ParseFormat(s); err != nil {
+			t.Errorf("ParseFormat(%q) returned unexpected error: %v", s, err)
+		}
+	}
+
+	for _, s := range []string{"yaml", "template:", ""} {
+		_, err := ParseFormat(s)
+		if err == nil || !strings.Contains(err.Error(), "unsupported format") || !strings.Contains(err.Error(), `"template:PATH"`) {
+			t.Errorf("ParseFormat(%q) error = %v, want an unsupported format error listing template:PATH", s, err)
+		}
+	}
+}
+
+func TestFormatExtension(t *testing.T) {
+	tests := map[Format]string{
+		XML:                         ".xml",
+		Markdown:                    ".md",
+		JSON:                        ".json",
+		JSONLines:                   ".jsonl",
+		JUnit:                       ".xml",
+		HTML:                        ".html",
+		"template:slack.txt.tmpl":   ".txt",
+		"template:t/prompt.md.tmpl": ".md",
+		"template:report.tmpl":      ".txt",
+	}
+	for format, want := range tests {
+		if got := format.Extension(); got != want {
+			t.Errorf("%q.Extension() = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestLookupFormatterTemplate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "count.tmpl")
+	if err := os.WriteFile(path, []byte("{{ len . }} actions\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := LookupFormatter(Format("template:" + path))
+	if err != nil {
+		t.Fatalf("LookupFormatter() returned unexpected error: %v", err)
+	}
+	if got, err := f.Format(make([]Result, 3)); err != nil || got != "3 actions" {
+		t.Errorf("Format() = %q, %v; want %q", got, err, "3 actions")
+	}
+
+	if _, err := LookupFormatter(Format("template:" + filepath.Join(dir, "missing.tmpl"))); err == nil || !strings.Contains(err.Error(), "reading the template") {
+		t.Errorf("LookupFormatter() error = %v, want a read error", err)
+	}
+}
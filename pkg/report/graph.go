@@ -0,0 +1,139 @@
+package report
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// checkNeeds validates the dependency graph declared by @name and @needs:
+// names must be unique, every need must name an action, and no action may
+// depend on itself, directly or through others.
+func checkNeeds(actions []Action) error {
+	index := map[string]int{}
+	for i, a := range actions {
+		if a.Name == "" {
+			continue
+		}
+		if _, dup := index[a.Name]; dup {
+			return fmt.Errorf("duplicate action name %q", a.Name)
+		}
+		index[a.Name] = i
+	}
+
+	for i, a := range actions {
+		for _, need := range a.Needs {
+			if _, ok := index[need]; !ok {
+				return fmt.Errorf("%s needs unknown action %q", actionRef(i, a), need)
+			}
+		}
+	}
+
+	if cycle := findCycle(actions, index); cycle != nil {
+		return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
+
+// actionRef names the action at index i in error messages.
+func actionRef(i int, a Action) string {
+	if a.Name != "" {
+		return fmt.Sprintf("action %q", a.Name)
+	}
+	return fmt.Sprintf("action %d", i+1)
+}
+
+// findCycle returns the names along a dependency cycle, starting and ending
+// with the same action, or nil when the graph is acyclic.
+func findCycle(actions []Action, index map[string]int) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(actions))
+	var path []string
+
+	var visit func(i int) []string
+	visit = func(i int) []string {
+		state[i] = visiting
+		path = append(path, actions[i].Name)
+
+		for _, need := range actions[i].Needs {
+			j, ok := index[need]
+			if !ok {
+				continue
+			}
+			switch state[j] {
+			case visiting:
+				start := slices.Index(path, need)
+				return append(slices.Clone(path[start:]), need)
+			case unvisited:
+				if cycle := visit(j); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+
+	for i := range actions {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// dependencies returns, for each action, the indices of the actions it needs.
+// Needs that name no action in the slice are ignored.
+func dependencies(actions []Action) [][]int {
+	index := map[string]int{}
+	for i, a := range actions {
+		if a.Name != "" {
+			index[a.Name] = i
+		}
+	}
+
+	deps := make([][]int, len(actions))
+	for i, a := range actions {
+		for _, need := range a.Needs {
+			if j, ok := index[need]; ok && j != i {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+	return deps
+}
+
+// withNeeds extends the selection keep with every action a selected action
+// needs, directly or through others.
+func withNeeds(actions []Action, keep []bool) {
+	deps := dependencies(actions)
+
+	var queue []int
+	for i, k := range keep {
+		if k {
+			queue = append(queue, i)
+		}
+	}
+
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, j := range deps[i] {
+			if !keep[j] {
+				keep[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+}
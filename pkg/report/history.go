@@ -0,0 +1,276 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// historyLayout names the file of a recorded run after the time it started,
+// in UTC, so that file names sort in the order the runs happened.
+const historyLayout = "20060102T150405.000000000Z"
+
+// HistoryRun is one recorded run of a named report.
+type HistoryRun struct {
+	// Time is when the run started.
+	Time time.Time
+	// Path is the XML report the run is stored in.
+	Path    string
+	Results []Result
+}
+
+// ValidateReportName checks that name can name a report history: a single
+// path element that does not start with a dot.
+func ValidateReportName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\:`) {
+		return fmt.Errorf("invalid report name: %q (expected a name without slashes or a leading dot)", name)
+	}
+	return nil
+}
+
+// RecordRun stores results as a run of the report name that started at
+// start, in an XML file under dir/name, and returns the file's path. The
+// file is written under a temporary name and renamed into place, so readers
+// never see half a run.
+func RecordRun(dir, name string, start time.Time, results []Result) (string, error) {
+	if err := ValidateReportName(name); err != nil {
+		return "", err
+	}
+
+	output, err := FormatXML(results)
+	if err != nil {
+		return "", err
+	}
+
+	runs := filepath.Join(dir, name)
+	if err := os.MkdirAll(runs, 0755); err != nil {
+		return "", fmt.Errorf("creating the history directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(runs, ".run-*.xml")
+	if err != nil {
+		return "", fmt.Errorf("recording the run: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(output + "\n"); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("recording the run: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("recording the run: %w", err)
+	}
+
+	path := filepath.Join(runs, start.UTC().Format(historyLayout)+".xml")
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("recording the run: %w", err)
+	}
+	return path, nil
+}
+
+// LoadHistory reads the recorded runs of the report name under dir, oldest
+// first. Files that are not named like a recorded run are ignored.
+func LoadHistory(dir, name string) ([]HistoryRun, error) {
+	if err := ValidateReportName(name); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no recorded runs of %q (record one with --record %s)", name, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []HistoryRun
+	for _, entry := range entries {
+		stem, ok := strings.CutSuffix(entry.Name(), ".xml")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		start, err := time.Parse(historyLayout, stem)
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(dir, name, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		results, err := ParseXML(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		runs = append(runs, HistoryRun{Time: start.Local(), Path: path, Results: results})
+	}
+
+	slices.SortFunc(runs, func(a, b HistoryRun) int { return a.Time.Compare(b.Time) })
+	return runs, nil
+}
+
+// ListHistories returns the names of the reports with a history under dir,
+// sorted.
+func ListHistories(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateReportName(entry.Name()) == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// ActionTrend follows one action of the latest run back through the history.
+type ActionTrend struct {
+	// Latest is the action's result in the latest run.
+	Latest Result
+	// Failures counts the runs the action failed in, out of the Seen runs
+	// it appears in.
+	Failures int
+	Seen     int
+	// FirstFailure is the index of the earliest run the action failed in,
+	// and FailingSince that of the first run of its current streak of
+	// failures; each is -1 when there is none.
+	FirstFailure int
+	FailingSince int
+}
+
+// ActionTrends follows each action of the latest of runs, which are oldest
+// first, back through the earlier ones. Actions are matched between runs as
+// DiffResults matches them. A run the action is missing from, or skipped
+// in, neither starts nor ends a streak of failures.
+func ActionTrends(runs []HistoryRun) []ActionTrend {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	latest := runs[len(runs)-1].Results
+	actions := make([]Action, len(latest))
+	for i, r := range latest {
+		actions[i] = r.Action
+	}
+
+	trends := make([]ActionTrend, len(latest))
+	streak := make([]bool, len(latest))
+	for i, r := range latest {
+		trends[i] = ActionTrend{Latest: r, FirstFailure: -1, FailingSince: -1}
+		streak[i] = true
+	}
+
+	for k := len(runs) - 1; k >= 0; k-- {
+		matched := LastResults(actions, runs[k].Results)
+		for i, r := range matched {
+			if r == nil {
+				continue
+			}
+			t := &trends[i]
+			t.Seen++
+
+			switch {
+			case r.Failed():
+				t.Failures++
+				t.FirstFailure = k
+				if streak[i] {
+					t.FailingSince = k
+				}
+			case !r.Skipped:
+				streak[i] = false
+			}
+		}
+	}
+
+	return trends
+}
+
+// FormatHistory renders the history of the report name as a Markdown
+// document: a table of runs, newest first, with their pass and fail counts
+// and duration, then a table of the actions of the latest run that ever
+// failed, with the run their current failures started in.
+func FormatHistory(name string, runs []HistoryRun) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Report History: %s\n", name)
+
+	if len(runs) == 0 {
+		b.WriteString("\nNo recorded runs.\n")
+		return b.String()
+	}
+
+	count := "1 run"
+	if len(runs) != 1 {
+		count = fmt.Sprintf("%d runs", len(runs))
+	}
+	fmt.Fprintf(&b, "\n%s · first %s · last %s\n", count, historyTime(runs[0].Time), historyTime(runs[len(runs)-1].Time))
+
+	b.WriteString("\n## Runs\n\n")
+	b.WriteString("| Run | Started | Actions | Passed | Failed | Skipped | Duration |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+	for k := len(runs) - 1; k >= 0; k-- {
+		results := runs[k].Results
+		passed := 0
+		for _, r := range results {
+			if r.Passed() {
+				passed++
+			}
+		}
+		fmt.Fprintf(&b, "| %d | %s | %d | %d | %d | %d | %s |\n",
+			k+1, historyTime(runs[k].Time), len(results), passed, CountFailed(results), CountSkipped(results),
+			formatDuration(reportSpan(results)))
+	}
+
+	b.WriteString("\n## Failures\n\n")
+
+	var rows []string
+	for _, t := range ActionTrends(runs) {
+		if t.FirstFailure < 0 {
+			continue
+		}
+
+		since := "passing"
+		if t.FailingSince >= 0 {
+			since = fmt.Sprintf("run %d (%s)", t.FailingSince+1, historyTime(runs[t.FailingSince].Time))
+		}
+		rows = append(rows, fmt.Sprintf("| %s | %s | %s | run %d (%s) | %d of %d |",
+			tableCell(actionLabel(t.Latest.Action)), verdict(t.Latest), since,
+			t.FirstFailure+1, historyTime(runs[t.FirstFailure].Time), t.Failures, t.Seen))
+	}
+
+	if len(rows) == 0 {
+		b.WriteString("No action of the latest run has failed in a recorded run.\n")
+		return b.String()
+	}
+
+	b.WriteString("| Action | Latest | Failing Since | First Failed | Failed Runs |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, row := range rows {
+		b.WriteString(row + "\n")
+	}
+
+	return b.String()
+}
+
+// historyTime formats the start of a recorded run.
+func historyTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// tableCell makes s safe to put in a Markdown table cell.
+func tableCell(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "|", `\|`)
+}
@@ -0,0 +1,122 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLoadHistory(t *testing.T) {
+	dir := t.TempDir()
+	first := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+
+	results := []Result{{Action: Action{Description: "Ping", Command: "ping -c1 db"}, ExitCode: 1, Output: "timeout"}}
+	for i, start := range []time.Time{first.Add(24 * time.Hour), first} {
+		path, err := RecordRun(dir, "nightly", start, results)
+		if err != nil {
+			t.Fatalf("RecordRun() returned unexpected error: %v", err)
+		}
+		if i == 0 && filepath.Base(path) != "20261016T080000.000000000Z.xml" {
+			t.Errorf("RecordRun() path = %q, want a file named after the start time", path)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "nightly", "notes.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := LoadHistory(dir, "nightly")
+	if err != nil {
+		t.Fatalf("LoadHistory() returned unexpected error: %v", err)
+	}
+	if len(runs) != 2 || !runs[0].Time.Equal(first) || !runs[1].Time.After(runs[0].Time) {
+		t.Fatalf("LoadHistory() = %+v, want the two runs oldest first", runs)
+	}
+	if got := runs[0].Results[0]; got.Action.Command != "ping -c1 db" || got.ExitCode != 1 || got.Output != "timeout" {
+		t.Errorf("loaded result = %+v, want the recorded one", got)
+	}
+
+	names, err := ListHistories(dir)
+	if err != nil || len(names) != 1 || names[0] != "nightly" {
+		t.Errorf("ListHistories() = %v, %v; want [nightly]", names, err)
+	}
+
+	if _, err := LoadHistory(dir, "weekly"); err == nil || !strings.Contains(err.Error(), `no recorded runs of "weekly"`) {
+		t.Errorf("LoadHistory() error = %v, want a no recorded runs error", err)
+	}
+}
+
+func TestValidateReportName(t *testing.T) {
+	for _, name := range []string{"nightly", "db-checks_2"} {
+		if err := ValidateReportName(name); err != nil {
+			t.Errorf("ValidateReportName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../x", "a/b", `a\b`, ".hidden"} {
+		if err := ValidateReportName(name); err == nil {
+			t.Errorf("ValidateReportName(%q) = nil, want an error", name)
+		}
+	}
+}
+
+// historyRuns returns five runs of two actions: "Build" always passes, and
+// "Test" fails in the first run, passes in the second, is skipped in the
+// fourth, and fails in the third and the fifth.
+func historyRuns() []HistoryRun {
+	start := time.Date(2026, 10, 13, 8, 0, 0, 0, time.UTC)
+	test := func(code int, skipped bool) Result {
+		return Result{Action: Action{Description: "Test", Command: "go test ./..."}, ExitCode: code, Skipped: skipped}
+	}
+
+	var runs []HistoryRun
+	for k, r := range []Result{test(1, false), test(0, false), test(2, false), test(-1, true), test(1, false)} {
+		begin := start.Add(time.Duration(k) * 24 * time.Hour)
+		runs = append(runs, HistoryRun{
+			Time: begin,
+			Results: []Result{
+				{Action: Action{Description: "Build", Command: "go build ./..."}, StartedAt: begin, FinishedAt: begin.Add(2 * time.Second)},
+				r,
+			},
+		})
+	}
+	return runs
+}
+
+func TestActionTrends(t *testing.T) {
+	trends := ActionTrends(historyRuns())
+	if len(trends) != 2 {
+		t.Fatalf("got %d trends, want 2", len(trends))
+	}
+
+	build, test := trends[0], trends[1]
+	if build.FirstFailure != -1 || build.FailingSince != -1 || build.Seen != 5 {
+		t.Errorf("Build trend = %+v, want no failures in 5 runs", build)
+	}
+	if test.FirstFailure != 0 || test.FailingSince != 2 || test.Failures != 3 || test.Seen != 5 {
+		t.Errorf("Test trend = %+v, want first failure 0, failing since 2, 3 of 5", test)
+	}
+}
+
+func TestFormatHistory(t *testing.T) {
+	got := FormatHistory("nightly", historyRuns())
+
+	for _, want := range []string{
+		"# Report History: nightly\n",
+		"5 runs · first 2026-10-13 08:00:00 · last 2026-10-17 08:00:00\n",
+		"| 5 | 2026-10-17 08:00:00 | 2 | 1 | 1 | 0 | 2s |\n",
+		"| 4 | 2026-10-16 08:00:00 | 2 | 1 | 0 | 1 | 2s |\n",
+		"| Test | failed | run 3 (2026-10-15 08:00:00) | run 1 (2026-10-13 08:00:00) | 3 of 5 |\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("FormatHistory() missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "| Build |") {
+		t.Errorf("FormatHistory() lists an action that never failed:\n%s", got)
+	}
+
+	if got := FormatHistory("nightly", historyRuns()[1:2]); !strings.Contains(got, "No action of the latest run has failed") {
+		t.Errorf("FormatHistory() of a passing run:\n%s", got)
+	}
+}
@@ -12,14 +12,26 @@ import "strings"
 //  5. No preceding # comment → Description is empty string
 //  6. Multiple consecutive # lines → last one wins
 //  7. Trailing # comment with no following command → ignored
-func ParseActions(text string) []Action {
+//  8. Comments starting with @ (e.g. "# @timeout 30s") → directives for the next action; they never
+//     replace the description. Unknown or malformed directives are an error naming the line.
+//  9. A Markdown code fence (``` or ~~~, with any info string such as "sh") → its contents, verbatim,
+//     become one action; an unterminated fence runs to the end of the input
+//  10. A statement left open at the end of a line → following lines join the command, newlines
+//     preserved, until it is complete: a here-document (<<EOF, <<-EOF, <<'EOF') until its delimiter, a
+//     compound command (if/fi, for/done, case/esac, { }, function f {) until its closer, an open
+//     quote, $( or ( until it closes, and a trailing |, &&, or || until the next command
+//  11. "# @name" and "# @needs" declare a dependency graph; a duplicate name, a need naming no action,
+//     or a dependency cycle is an error
+//  12. "# @matrix KEY=a,b" marks the action for expansion into one action per value by ExpandActions,
+//     which also fills {{ .Var }} placeholders; ParseActions keeps placeholders as written
+func ParseActions(text string) ([]Action, error) {
 	if text == "" {
-		return []Action{}
+		return []Action{}, nil
 	}
 
 	lines := strings.Split(text, "\n")
 	actions := []Action{}
-	var description string
+	var pending Action
 
 	for i := 0; i < len(lines); i++ {
 		line := lines[i]
@@ -32,30 +44,135 @@ func ParseActions(text string) []Action {
 		// Rule 1: comment line (trim leading whitespace before checking)
 		trimmed := strings.TrimSpace(line)
 		if strings.HasPrefix(trimmed, "#") {
-			description = strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+			comment := strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+
+			// Rule 8: directive line
+			if isDirective(comment) {
+				if err := applyDirective(&pending, comment, i+1); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			pending.Description = comment
+			continue
+		}
+
+		// Rule 9: a Markdown code fence is one action, taken verbatim
+		if marker := fenceMarker(line); marker != "" {
+			var body []string
+			for i++; i < len(lines) && !closesFence(lines[i], marker); i++ {
+				body = append(body, lines[i])
+			}
+
+			if strings.TrimSpace(strings.Join(body, "")) == "" {
+				continue
+			}
+
+			pending.Command = strings.Join(body, "\n")
+			actions = append(actions, pending)
+			pending = Action{}
 			continue
 		}
 
-		// Rule 2 & 4: command line, possibly with continuation
-		cmd := line
+		// Rules 2, 4 & 10: command line, possibly with continuations,
+		// here-documents, and multi-line compound commands
+		var cmd string
+		cmd, i = readCommand(lines, i)
+
+		pending.Command = cmd
+		actions = append(actions, pending)
+		pending = Action{}
+	}
+
+	if err := checkNeeds(actions); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
+
+// FilterByTags returns the actions carrying at least one of tags, together
+// with every action they need, in input order. An empty tags list returns
+// actions unchanged.
+func FilterByTags(actions []Action, tags []string) []Action {
+	if len(tags) == 0 {
+		return actions
+	}
+
+	wanted := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		wanted[t] = true
+	}
+
+	keep := make([]bool, len(actions))
+	for i, a := range actions {
+		for _, t := range a.Tags {
+			if wanted[t] {
+				keep[i] = true
+				break
+			}
+		}
+	}
+	withNeeds(actions, keep)
+
+	filtered := []Action{}
+	for i, a := range actions {
+		if keep[i] {
+			filtered = append(filtered, a)
+		}
+	}
+
+	return filtered
+}
 
-		for strings.HasSuffix(strings.TrimRight(cmd, " \t"), `\`) {
-			cmd = strings.TrimRight(cmd, " \t")
-			cmd = cmd[:len(cmd)-1]          // remove trailing '\'
-			cmd = strings.TrimRight(cmd, " \t")
+// readCommand reads the command starting at lines[i] and returns it together
+// with the index of its last line. Continuation lines are joined with a
+// space; here-document bodies are kept verbatim, and the lines of a statement
+// that is still open (see incompleteShell) are kept on their own lines.
+func readCommand(lines []string, i int) (string, int) {
+	var cmdLines []string
 
-			i++
-			if i < len(lines) {
-				cmd += " " + strings.TrimLeft(lines[i], " \t")
+	for {
+		var line string
+		line, i = joinContinuations(lines, i)
+		cmdLines = append(cmdLines, line)
+
+		for _, h := range lineHeredocs(line) {
+			for i+1 < len(lines) {
+				i++
+				cmdLines = append(cmdLines, lines[i])
+				if h.closes(lines[i]) {
+					break
+				}
 			}
 		}
 
-		actions = append(actions, Action{
-			Description: description,
-			Command:     cmd,
-		})
-		description = ""
+		if i+1 >= len(lines) || !incompleteShell(strings.Join(cmdLines, "\n")) {
+			break
+		}
+		i++
+	}
+
+	return strings.Join(cmdLines, "\n"), i
+}
+
+// joinContinuations joins lines[i] with the following lines while it ends
+// in a backslash, and returns the logical line and the index of its last line.
+func joinContinuations(lines []string, i int) (string, int) {
+	cmd := lines[i]
+
+	for strings.HasSuffix(strings.TrimRight(cmd, " \t"), `\`) {
+		cmd = strings.TrimRight(cmd, " \t")
+		cmd = cmd[:len(cmd)-1] // remove trailing '\'
+		cmd = strings.TrimRight(cmd, " \t")
+
+		if i+1 >= len(lines) {
+			break
+		}
+		i++
+		cmd += " " + strings.TrimLeft(lines[i], " \t")
 	}
 
-	return actions
+	return cmd, i
 }
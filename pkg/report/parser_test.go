@@ -1,7 +1,10 @@
 package report
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseActions(t *testing.T) {
@@ -91,7 +94,10 @@ func TestParseActions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ParseActions(tt.input)
+			got, err := ParseActions(tt.input)
+			if err != nil {
+				t.Fatalf("ParseActions() returned unexpected error: %v", err)
+			}
 
 			if len(got) != len(tt.expected) {
 				t.Fatalf("ParseActions() returned %d actions, want %d\ngot:  %+v\nwant: %+v", len(got), len(tt.expected), got, tt.expected)
@@ -108,3 +114,424 @@ func TestParseActions(t *testing.T) {
 		})
 	}
 }
+
+func TestParseActionsMultiLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Action
+	}{
+		{
+			name:  "heredoc",
+			input: "# write config\ncat <<EOF > app.conf\nport=80\n# not a comment\nEOF\nls",
+			expected: []Action{
+				{Description: "write config", Command: "cat <<EOF > app.conf\nport=80\n# not a comment\nEOF"},
+				{Command: "ls"},
+			},
+		},
+		{
+			name:  "quoted heredoc delimiter",
+			input: "cat <<'END'\n$HOME \\\nEND\npwd",
+			expected: []Action{
+				{Command: "cat <<'END'\n$HOME \\\nEND"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "tab-stripping heredoc",
+			input: "cat <<-EOF\n\tindented\n\tEOF\npwd",
+			expected: []Action{
+				{Command: "cat <<-EOF\n\tindented\n\tEOF"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "two heredocs on one line",
+			input: "paste /dev/fd/3 3<<A /dev/fd/4 4<<B\na\nA\nb\nB\npwd",
+			expected: []Action{
+				{Command: "paste /dev/fd/3 3<<A /dev/fd/4 4<<B\na\nA\nb\nB"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "unterminated heredoc runs to the end",
+			input: "cat <<EOF\nline\nls",
+			expected: []Action{
+				{Command: "cat <<EOF\nline\nls"},
+			},
+		},
+		{
+			name:  "here-string and quoted or arithmetic << are not heredocs",
+			input: "cat <<< hi\necho \"<<EOF\"\necho $((1<<2))\nls",
+			expected: []Action{
+				{Command: "cat <<< hi"},
+				{Command: "echo \"<<EOF\""},
+				{Command: "echo $((1<<2))"},
+				{Command: "ls"},
+			},
+		},
+		{
+			name:  "if block",
+			input: "# check\nif [ -f x ]; then\n  echo yes\nelse\n  echo no\nfi\npwd",
+			expected: []Action{
+				{Description: "check", Command: "if [ -f x ]; then\n  echo yes\nelse\n  echo no\nfi"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "nested loops",
+			input: "for a in 1 2; do\n  while read b; do\n    echo $a $b\n  done < f\ndone\npwd",
+			expected: []Action{
+				{Command: "for a in 1 2; do\n  while read b; do\n    echo $a $b\n  done < f\ndone"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "case statement",
+			input: "case $x in\n  a) echo a ;;\n  *) echo other ;;\nesac\npwd",
+			expected: []Action{
+				{Command: "case $x in\n  a) echo a ;;\n  *) echo other ;;\nesac"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "function with braces",
+			input: "greet() {\n  echo hi\n}; greet\npwd",
+			expected: []Action{
+				{Command: "greet() {\n  echo hi\n}; greet"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "one-line compound commands stay single actions",
+			input: "if true; then echo a; fi\nfor i in 1 2; do echo $i; done\npwd",
+			expected: []Action{
+				{Command: "if true; then echo a; fi"},
+				{Command: "for i in 1 2; do echo $i; done"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "reserved words outside command position are ignored",
+			input: "echo if for while\necho \"if\" # for\npwd",
+			expected: []Action{
+				{Command: "echo if for while"},
+				{Command: "echo \"if\" # for"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "continuation inside a block",
+			input: "if true; then\n  echo a \\\n    b\nfi",
+			expected: []Action{
+				{Command: "if true; then\n  echo a b\nfi"},
+			},
+		},
+		{
+			name:  "double-quoted string spanning lines",
+			input: "echo \"a\nb\"\npwd",
+			expected: []Action{
+				{Command: "echo \"a\nb\""},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "single-quoted string spanning lines",
+			input: "printf '%s\n' 'one\ntwo'\npwd",
+			expected: []Action{
+				{Command: "printf '%s\n' 'one\ntwo'"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "command substitution spanning lines",
+			input: "v=$(\n  echo hi\n)\npwd",
+			expected: []Action{
+				{Command: "v=$(\n  echo hi\n)"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "trailing pipe",
+			input: "echo one |\n  tr o 0\npwd",
+			expected: []Action{
+				{Command: "echo one |\n  tr o 0"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "trailing && and ||",
+			input: "make build &&\n  make test ||\n  echo failed\npwd",
+			expected: []Action{
+				{Command: "make build &&\n  make test ||\n  echo failed"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "bash function keyword",
+			input: "function foo {\n  echo hi\n}\npwd",
+			expected: []Action{
+				{Command: "function foo {\n  echo hi\n}"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "array spanning lines",
+			input: "hosts=(\n  web1\n  web2\n)\npwd",
+			expected: []Action{
+				{Command: "hosts=(\n  web1\n  web2\n)"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "syntax errors end the command",
+			input: "echo hi )\npwd",
+			expected: []Action{
+				{Command: "echo hi )"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "fenced block is one action",
+			input: "# from the runbook\n```sh\n# a shell comment\ncd /tmp\nls \\\n  -la\n```\npwd",
+			expected: []Action{
+				{Description: "from the runbook", Command: "# a shell comment\ncd /tmp\nls \\\n  -la"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "tilde fence with longer closer",
+			input: "~~~bash\necho hi\n~~~~\npwd",
+			expected: []Action{
+				{Command: "echo hi"},
+				{Command: "pwd"},
+			},
+		},
+		{
+			name:  "empty fence is skipped",
+			input: "# described\n```\n```\nls",
+			expected: []Action{
+				{Description: "described", Command: "ls"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseActions(tt.input)
+			if err != nil {
+				t.Fatalf("ParseActions() returned unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseActions() =\n%#v\nwant\n%#v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseActionsDirectives(t *testing.T) {
+	input := strings.Join([]string{
+		"# Wait for the API",
+		"# @timeout 30s",
+		"# @retries 2",
+		"# @max-output-bytes 64KiB",
+		"# @max-output-lines 200",
+		"# @expect-exit 1",
+		"# @cwd ./sub",
+		"# @env FOO=bar",
+		"# @env BAZ=a=b",
+		"# @allow-failure",
+		"# @tag smoke",
+		"# @tag db slow",
+		"# @expect-output-contains ready",
+		`# @expect-output-match ^v\d+\.`,
+		"# @matrix region=us,eu tier=web",
+		"curl localhost",
+		"echo plain",
+	}, "\n")
+
+	got, err := ParseActions(input)
+	if err != nil {
+		t.Fatalf("ParseActions() returned unexpected error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("ParseActions() returned %d actions, want 2", len(got))
+	}
+
+	want := Action{
+		Description:    "Wait for the API",
+		Command:        "curl localhost",
+		Timeout:        30 * time.Second,
+		Retries:        2,
+		MaxOutputBytes: 64 << 10,
+		MaxOutputLines: 200,
+		ExpectExit:     1,
+		Dir:            "./sub",
+		Env:            []string{"FOO=bar", "BAZ=a=b"},
+		AllowFailure:   true,
+		Tags:           []string{"smoke", "db", "slow"},
+		Assertions: []Assertion{
+			{Kind: OutputContains, Pattern: "ready"},
+			{Kind: OutputMatches, Pattern: `^v\d+\.`},
+		},
+		Matrix: []MatrixAxis{
+			{Key: "region", Values: []string{"us", "eu"}},
+			{Key: "tier", Values: []string{"web"}},
+		},
+	}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("action[0] = %+v\nwant        %+v", got[0], want)
+	}
+
+	if !reflect.DeepEqual(got[1], Action{Command: "echo plain"}) {
+		t.Errorf("directives leaked into the next action: %+v", got[1])
+	}
+}
+
+func TestParseActionsDirectiveDoesNotReplaceDescription(t *testing.T) {
+	got, err := ParseActions("# Describe me\n# @tag smoke\nls")
+	if err != nil {
+		t.Fatalf("ParseActions() returned unexpected error: %v", err)
+	}
+	if got[0].Description != "Describe me" {
+		t.Errorf("Description = %q, want %q", got[0].Description, "Describe me")
+	}
+}
+
+func TestParseActionsDirectiveErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "unknown directive", input: "ls\n\n# @retry 3\npwd", want: `line 3: unknown directive "@retry"`},
+		{name: "invalid timeout", input: "# @timeout soon\nls", want: `line 1: invalid @timeout "soon"`},
+		{name: "non-positive timeout", input: "# @timeout 0s\nls", want: `line 1: invalid @timeout "0s"`},
+		{name: "invalid retries", input: "# @retries many\nls", want: `line 1: invalid @retries "many"`},
+		{name: "zero retries", input: "# @retries 0\nls", want: `line 1: invalid @retries "0"`},
+		{name: "invalid output size", input: "# @max-output-bytes lots\nls", want: `line 1: invalid @max-output-bytes "lots"`},
+		{name: "zero output lines", input: "# @max-output-lines 0\nls", want: `line 1: invalid @max-output-lines "0"`},
+		{name: "missing argument", input: "# @cwd\nls", want: "line 1: directive @cwd requires an argument"},
+		{name: "invalid exit code", input: "# @expect-exit one\nls", want: `line 1: invalid @expect-exit "one"`},
+		{name: "env without value", input: "# @env FOO\nls", want: `line 1: invalid @env "FOO"`},
+		{name: "invalid output regexp", input: "# @expect-output-match ([a-z]\nls", want: `line 1: invalid @expect-output-match "([a-z]"`},
+		{name: "allow-failure with argument", input: "# @allow-failure yes\nls", want: "line 1: directive @allow-failure takes no argument"},
+		{name: "matrix without values", input: "# @matrix region\nls", want: `line 1: invalid @matrix "region"`},
+		{name: "matrix with an empty value", input: "# @matrix region=us,\nls", want: `line 1: invalid @matrix "region=us,"`},
+		{name: "matrix key not a name", input: "# @matrix my-region=us\nls", want: `line 1: invalid @matrix "my-region=us"`},
+		{name: "duplicate matrix key", input: "# @matrix region=us\n# @matrix region=eu\nls", want: `line 2: duplicate @matrix key "region"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseActions(tt.input)
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseActionsNeeds(t *testing.T) {
+	input := strings.Join([]string{
+		"# @needs build",
+		"make test",
+		"# @name build",
+		"make",
+		"# @name lint",
+		"# @needs build test",
+		"make lint",
+		"# @name test",
+		"echo test",
+	}, "\n")
+
+	got, err := ParseActions(input)
+	if err != nil {
+		t.Fatalf("ParseActions() returned unexpected error: %v", err)
+	}
+
+	want := []Action{
+		{Command: "make test", Needs: []string{"build"}},
+		{Name: "build", Command: "make"},
+		{Name: "lint", Needs: []string{"build", "test"}, Command: "make lint"},
+		{Name: "test", Command: "echo test"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseActions() = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseActionsNeedsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "name with spaces", input: "# @name build all\nmake", want: `line 1: invalid @name "build all"`},
+		{name: "missing needs", input: "# @needs\nmake", want: "line 1: directive @needs requires an argument"},
+		{name: "duplicate name", input: "# @name a\nls\n# @name a\npwd", want: `duplicate action name "a"`},
+		{name: "unknown need", input: "ls\n# @needs setup\npwd", want: `action 2 needs unknown action "setup"`},
+		{name: "self dependency", input: "# @name a\n# @needs a\nls", want: "dependency cycle: a -> a"},
+		{
+			name:  "indirect cycle",
+			input: "# @name a\n# @needs c\nls\n# @name b\n# @needs a\npwd\n# @name c\n# @needs b\nid",
+			want:  "dependency cycle: a -> c -> b -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseActions(tt.input)
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterByTags(t *testing.T) {
+	actions := []Action{
+		{Command: "a", Tags: []string{"smoke"}},
+		{Command: "b"},
+		{Command: "c", Tags: []string{"db", "slow"}},
+	}
+
+	if got := FilterByTags(actions, nil); len(got) != 3 {
+		t.Errorf("FilterByTags(nil) returned %d actions, want 3", len(got))
+	}
+
+	got := FilterByTags(actions, []string{"slow", "smoke"})
+	if len(got) != 2 || got[0].Command != "a" || got[1].Command != "c" {
+		t.Errorf("FilterByTags(slow, smoke) = %+v, want actions a and c", got)
+	}
+
+	if got := FilterByTags(actions, []string{"missing"}); len(got) != 0 {
+		t.Errorf("FilterByTags(missing) returned %d actions, want 0", len(got))
+	}
+}
+
+func TestFilterByTagsKeepsNeeds(t *testing.T) {
+	actions := []Action{
+		{Name: "cluster", Command: "kubectl cluster-info"},
+		{Name: "ns", Command: "kubectl get ns", Needs: []string{"cluster"}},
+		{Command: "date"},
+		{Command: "kubectl get pods", Needs: []string{"ns"}, Tags: []string{"smoke"}},
+	}
+
+	got := FilterByTags(actions, []string{"smoke"})
+
+	var commands []string
+	for _, a := range got {
+		commands = append(commands, a.Command)
+	}
+	want := []string{"kubectl cluster-info", "kubectl get ns", "kubectl get pods"}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("FilterByTags(smoke) = %q, want %q", commands, want)
+	}
+}
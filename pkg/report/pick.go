@@ -0,0 +1,102 @@
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LastResults matches each action to its result in a saved run, the way
+// DiffResults pairs the actions of two runs. An action without a result in
+// last gets nil.
+func LastResults(actions []Action, last []Result) []*Result {
+	current := make([]Result, len(actions))
+	for i, a := range actions {
+		current[i] = Result{Action: a}
+	}
+
+	matched := make([]*Result, len(actions))
+	for i, d := range DiffResults(last, current)[:len(actions)] {
+		matched[i] = d.Old
+	}
+	return matched
+}
+
+// PickItems returns the lines shown by the --pick selector: one per action,
+// holding its index and a tab, then its label and, when it has a last
+// result, that result's verdict. Labels are kept to one line.
+func PickItems(actions []Action, last []*Result) []string {
+	items := make([]string, len(actions))
+	for i, a := range actions {
+		label := strings.Join(strings.Fields(actionLabel(a)), " ")
+		if i < len(last) && last[i] != nil {
+			label += " [" + verdict(*last[i]) + "]"
+		}
+		items[i] = fmt.Sprintf("%d\t%s", i, label)
+	}
+	return items
+}
+
+// PickPreview returns the text the --pick selector previews for action: its
+// description and command, then the exit status and output of last, or a
+// note that it has no previous result.
+func PickPreview(action Action, last *Result) string {
+	var b strings.Builder
+
+	if action.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", action.Description)
+	}
+	fmt.Fprintf(&b, "$ %s\n", action.Command)
+
+	if last == nil {
+		b.WriteString("\n(no previous result)\n")
+		return b.String()
+	}
+
+	status := fmt.Sprintf("%s · exit %d · %s", verdict(*last), last.ExitCode, formatDuration(last.Duration))
+	if note := statusNote(*last); note != "" {
+		status += " (" + note + ")"
+	}
+	fmt.Fprintf(&b, "\nLast run: %s\n", status)
+
+	if output := strings.TrimRight(last.Output, "\n"); output != "" {
+		fmt.Fprintf(&b, "\n%s\n", output)
+	}
+	return b.String()
+}
+
+// ParsePicked reads the indices at the start of the lines the selector
+// printed for the chosen items, given n actions.
+func ParsePicked(output string, n int) ([]int, error) {
+	var indices []int
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		field, _, _ := strings.Cut(line, "\t")
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 0 || i >= n {
+			return nil, fmt.Errorf("unexpected selection: %q", line)
+		}
+		indices = append(indices, i)
+	}
+	return indices, nil
+}
+
+// SelectActions returns the actions at indices, in input order, plus every
+// action they need.
+func SelectActions(actions []Action, indices []int) []Action {
+	keep := make([]bool, len(actions))
+	for _, i := range indices {
+		keep[i] = true
+	}
+	withNeeds(actions, keep)
+
+	selected := []Action{}
+	for i, a := range actions {
+		if keep[i] {
+			selected = append(selected, a)
+		}
+	}
+	return selected
+}
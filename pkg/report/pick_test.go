@@ -0,0 +1,91 @@
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPickItems(t *testing.T) {
+	actions := []Action{
+		{Description: "Build", Command: "go build ./..."},
+		{Command: "for f in *; do\n  echo $f\ndone"},
+		{Command: "new"},
+	}
+	last := LastResults(actions, []Result{
+		{Action: Action{Command: "for f in *; do\n  echo $f\ndone"}, ExitCode: 1},
+		{Action: Action{Description: "Build", Command: "go build ./..."}},
+	})
+
+	want := []string{
+		"0\tBuild [passed]",
+		"1\tfor f in *; do [failed]",
+		"2\tnew",
+	}
+	if got := PickItems(actions, last); !reflect.DeepEqual(got, want) {
+		t.Errorf("PickItems() = %q, want %q", got, want)
+	}
+}
+
+func TestPickPreview(t *testing.T) {
+	action := Action{Description: "Test", Command: "go test ./..."}
+
+	got := PickPreview(action, nil)
+	if want := "Test\n\n$ go test ./...\n\n(no previous result)\n"; got != want {
+		t.Errorf("PickPreview(nil) = %q, want %q", got, want)
+	}
+
+	last := &Result{Action: action, ExitCode: 2, Duration: 1500 * time.Millisecond, Output: "FAIL\n"}
+	got = PickPreview(action, last)
+	for _, want := range []string{"$ go test ./...", "Last run: failed · exit 2 · 1.5s", "\nFAIL\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("PickPreview() = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestParsePicked(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []int
+		wantErr string
+	}{
+		{name: "chosen lines", output: "2\tlint\n0\tbuild [passed]\n", want: []int{2, 0}},
+		{name: "nothing chosen", output: "", want: nil},
+		{name: "not an index", output: "build\n", wantErr: `unexpected selection: "build"`},
+		{name: "out of range", output: "3\tgone\n", wantErr: `unexpected selection: "3\tgone"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePicked(tt.output, 3)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParsePicked() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePicked() returned unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePicked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectActions(t *testing.T) {
+	actions := []Action{
+		{Name: "build", Command: "make"},
+		{Command: "lint"},
+		{Command: "test", Needs: []string{"build"}},
+	}
+
+	got := SelectActions(actions, []int{2})
+	if len(got) != 2 || got[0].Command != "make" || got[1].Command != "test" {
+		t.Errorf("SelectActions() = %+v, want the picked action and the one it needs", got)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+
+package report
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// setProcessGroup runs cmd in its own process group and makes context
+// cancellation kill the whole group, so children spawned by the shell
+// (pipelines, background jobs) die with it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// maxRSS returns the peak resident set size, in bytes, of the process that
+// produced state and the children it waited for, or 0 when it cannot be told
+// apart from the size of this process.
+//
+// On Linux, Go starts children with vfork, so a child's peak starts out at
+// the peak of this process. A figure no larger than that says nothing about
+// the command, and is dropped.
+func maxRSS(state *os.ProcessState) int {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+
+	if runtime.GOOS == "linux" {
+		var self syscall.Rusage
+		if err := syscall.Getrusage(syscall.RUSAGE_SELF, &self); err != nil || usage.Maxrss <= self.Maxrss {
+			return 0
+		}
+	}
+
+	// Linux and the BSDs report kilobytes; macOS reports bytes.
+	if runtime.GOOS == "darwin" {
+		return int(usage.Maxrss)
+	}
+	return int(usage.Maxrss) * 1024
+}
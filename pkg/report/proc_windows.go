@@ -0,0 +1,17 @@
+//go:build windows
+
+package report
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows; context cancellation falls back to
+// killing the shell process only.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// maxRSS is not reported on Windows.
+func maxRSS(state *os.ProcessState) int {
+	return 0
+}
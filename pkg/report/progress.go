@@ -0,0 +1,290 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cloudbridgeuy/scripts/pkg/styles"
+)
+
+const (
+	// progressInterval is how often the live view is redrawn.
+	progressInterval = 100 * time.Millisecond
+	// progressTailLines is how many trailing output lines a running action shows.
+	progressTailLines = 3
+	// progressTailBytes bounds the output kept per action for its tail.
+	progressTailBytes = 4096
+)
+
+// spinnerFrames animate the glyph of a running action.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// ansiEscape matches the terminal escape sequences stripped from output tails.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b.`)
+
+type rowState int
+
+const (
+	rowPending rowState = iota
+	rowRunning
+	rowDone
+)
+
+// progressRow is the live state of one action.
+type progressRow struct {
+	action  Action
+	state   rowState
+	started time.Time
+	result  Result
+	tail    []byte
+}
+
+// Progress draws a live view of a run on a terminal: a spinner, the elapsed
+// time, and the tail of its output for each running action, and a pass/fail
+// glyph for each finished one. Wire Start, Output, and Finish into ExecConfig,
+// call Run in its own goroutine, and call Stop before writing anything else;
+// Stop erases the view so only what is printed afterwards remains.
+type Progress struct {
+	mu     sync.Mutex
+	w      io.Writer
+	styles styles.Styles
+	rows   []progressRow
+	width  int
+	height int
+	frame  int
+	drawn  int
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewProgress returns a Progress for actions that draws to w with styles
+// from r. width and height are the terminal size; zero means unbounded.
+func NewProgress(w io.Writer, r *lipgloss.Renderer, actions []Action, width, height int) *Progress {
+	rows := make([]progressRow, len(actions))
+	for i, action := range actions {
+		rows[i] = progressRow{action: action}
+	}
+
+	return &Progress{
+		w:      w,
+		styles: styles.MakeStyles(r),
+		rows:   rows,
+		width:  width,
+		height: height,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start marks the action at index as running.
+func (p *Progress) Start(index int, action Action) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rows[index].state = rowRunning
+	p.rows[index].started = time.Now()
+}
+
+// Output records a chunk of the action's output for its tail.
+func (p *Progress) Output(index int, chunk []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tail := append(p.rows[index].tail, chunk...)
+	if len(tail) > progressTailBytes {
+		tail = append([]byte(nil), tail[len(tail)-progressTailBytes:]...)
+	}
+	p.rows[index].tail = tail
+}
+
+// Finish marks the action at index as done with result.
+func (p *Progress) Finish(index int, result Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rows[index].state = rowDone
+	p.rows[index].result = result
+	p.rows[index].tail = nil
+}
+
+// Run redraws the view until Stop is called.
+func (p *Progress) Run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	fmt.Fprint(p.w, "\033[?25l")
+	defer fmt.Fprint(p.w, "\033[?25h")
+
+	for {
+		p.draw()
+
+		select {
+		case <-p.stop:
+			p.mu.Lock()
+			p.erase()
+			p.mu.Unlock()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop erases the view and waits for Run to return.
+func (p *Progress) Stop() {
+	close(p.stop)
+	<-p.done
+}
+
+// draw replaces the previous frame with a fresh one.
+func (p *Progress) draw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	frame := renderProgress(p.rows, p.styles, time.Now(), p.frame, p.width, p.height)
+	p.frame++
+
+	p.erase()
+	fmt.Fprint(p.w, frame)
+	p.drawn = strings.Count(frame, "\n")
+}
+
+// erase moves the cursor back to the top of the last frame and clears it.
+func (p *Progress) erase() {
+	if p.drawn > 0 {
+		fmt.Fprintf(p.w, "\033[%dA\r", p.drawn)
+	}
+	fmt.Fprint(p.w, "\033[J")
+	p.drawn = 0
+}
+
+// renderProgress renders one frame of the live view: a summary line, then
+// one line per action followed by the output tail of running actions. When
+// the frame would not fit in height lines, the oldest lines are elided.
+func renderProgress(rows []progressRow, s styles.Styles, now time.Time, frame, width, height int) string {
+	var body []string
+	done, failed := 0, 0
+
+	for _, row := range rows {
+		label := actionLabel(row.action)
+
+		switch row.state {
+		case rowPending:
+			body = append(body, s.Comment.Render("· "+truncate(label, width-2)))
+
+		case rowRunning:
+			spinner := s.CyclingChars.Render(spinnerFrames[frame%len(spinnerFrames)])
+			elapsed := now.Sub(row.started).Truncate(100 * time.Millisecond).String()
+			body = append(body, fmt.Sprintf("%s %s  %s", spinner, truncate(label, width-len(elapsed)-4), s.Comment.Render(elapsed)))
+			for _, line := range tailLines(row.tail, progressTailLines) {
+				body = append(body, s.Comment.Render("  │ "+truncate(line, width-4)))
+			}
+
+		case rowDone:
+			done++
+			r := row.result
+			duration := formatDuration(r.Duration)
+			glyph, suffix := s.Passed.Render("✓"), ""
+
+			switch {
+			case r.Skipped:
+				glyph, suffix = s.Comment.Render("↷"), "  "+s.Comment.Render(statusNote(r))
+			case r.Failed():
+				failed++
+				glyph, suffix = s.Failed.Render("✗"), "  "+s.Failed.Render(outcome(r))
+			case !r.Passed():
+				glyph, suffix = s.Comment.Render("✗"), "  "+s.Comment.Render(outcome(r))
+			}
+
+			budget := width - len(duration) - 4 - lipgloss.Width(suffix)
+			body = append(body, fmt.Sprintf("%s %s  %s%s", glyph, truncate(label, budget), s.Comment.Render(duration), suffix))
+		}
+	}
+
+	summary := fmt.Sprintf("%d/%d done", done, len(rows))
+	if failed > 0 {
+		summary += fmt.Sprintf(" · %d failed", failed)
+	}
+	const title = "scripts report"
+	header := s.AppName.Render(title) + " " + s.Comment.Render(truncate(summary, width-len(title)-1))
+
+	if height > 3 && len(body) > height-2 {
+		keep := height - 3
+		hidden := len(body) - keep
+		body = append([]string{s.Comment.Render(fmt.Sprintf("… %d more lines", hidden))}, body[hidden:]...)
+	}
+
+	return header + "\n" + strings.Join(body, "\n") + "\n"
+}
+
+// actionLabel names an action by its description, or else the first line of
+// its command.
+func actionLabel(action Action) string {
+	if action.Description != "" {
+		return action.Description
+	}
+	label, _, _ := strings.Cut(action.Command, "\n")
+	return label
+}
+
+// outcome summarizes why a finished action did not pass, e.g. "exit 2" or
+// "exit 0 · 1 assertion failed".
+func outcome(r Result) string {
+	text := fmt.Sprintf("exit %d", r.ExitCode)
+	if note := statusNote(r); note != "" {
+		text += " (" + note + ")"
+	}
+	if n := len(r.FailedAssertions()); n == 1 {
+		text += " · 1 assertion failed"
+	} else if n > 1 {
+		text += fmt.Sprintf(" · %d assertions failed", n)
+	}
+	return text
+}
+
+// tailLines returns up to n of the last non-blank lines of output, as a
+// terminal would show them: escape sequences and control characters are
+// removed and a carriage return keeps only what follows it.
+func tailLines(output []byte, n int) []string {
+	text := ansiEscape.ReplaceAllString(string(output), "")
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if i := strings.LastIndex(line, "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		line = strings.ReplaceAll(line, "\t", "    ")
+		line = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, line)
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimRight(line, " "))
+		}
+	}
+
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// truncate shortens s to at most width runes, marking the cut with "…".
+// A width below 1 leaves s unchanged.
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width < 1 || len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
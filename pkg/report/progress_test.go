@@ -0,0 +1,109 @@
+package report
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cloudbridgeuy/scripts/pkg/styles"
+)
+
+// plainStyles renders without colour, so frames compare as plain text.
+func plainStyles() styles.Styles {
+	return styles.MakeStyles(lipgloss.NewRenderer(io.Discard))
+}
+
+func TestRenderProgress(t *testing.T) {
+	now := time.Now()
+	rows := []progressRow{
+		{
+			action: Action{Description: "Build"},
+			state:  rowDone,
+			result: Result{Duration: 1500 * time.Millisecond},
+		},
+		{
+			action: Action{Command: "make test\nmake lint"},
+			state:  rowDone,
+			result: Result{ExitCode: 2, Duration: 20 * time.Millisecond},
+		},
+		{
+			action: Action{Command: "flaky", AllowFailure: true},
+			state:  rowDone,
+			result: Result{Action: Action{AllowFailure: true}, ExitCode: 1},
+		},
+		{
+			action:  Action{Description: "Deploy"},
+			state:   rowRunning,
+			started: now.Add(-2300 * time.Millisecond),
+			tail:    []byte("one\ntwo\n\x1b[32mthree\x1b[0m\nfour\n"),
+		},
+		{action: Action{Description: "Smoke test"}},
+	}
+
+	got := renderProgress(rows, plainStyles(), now, 1, 0, 0)
+	want := strings.Join([]string{
+		"scripts report 3/5 done · 1 failed",
+		"✓ Build  1.5s",
+		"✗ make test  20ms  exit 2",
+		"✗ flaky  0s  exit 1 (failure allowed)",
+		"⠙ Deploy  2.3s",
+		"  │ two",
+		"  │ three",
+		"  │ four",
+		"· Smoke test",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("renderProgress() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderProgressFitsTerminal(t *testing.T) {
+	rows := make([]progressRow, 10)
+	for i := range rows {
+		rows[i] = progressRow{action: Action{Description: strings.Repeat("x", 40)}}
+	}
+
+	got := renderProgress(rows, plainStyles(), time.Now(), 0, 20, 6)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	if len(lines) != 5 {
+		t.Errorf("got %d lines, want 5 to fit a 6-line terminal:\n%s", len(lines), got)
+	}
+	if lines[1] != "… 7 more lines" {
+		t.Errorf("lines[1] = %q, want the elided line count", lines[1])
+	}
+	for _, line := range lines {
+		if n := len([]rune(line)); n > 20 {
+			t.Errorf("line %q is %d columns wide, want at most 20", line, n)
+		}
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{name: "empty", output: "", want: nil},
+		{name: "last lines", output: "a\nb\nc\n", want: []string{"b", "c"}},
+		{name: "partial last line", output: "a\nb\nc", want: []string{"b", "c"}},
+		{name: "blank lines skipped", output: "a\n\n  \nb\n", want: []string{"a", "b"}},
+		{name: "carriage return overwrites", output: "10%\r50%\r100%\n", want: []string{"100%"}},
+		{name: "crlf endings", output: "a\r\nb\r\n", want: []string{"a", "b"}},
+		{name: "escapes stripped", output: "\x1b[1;31mred\x1b[0m\n\x1b]0;title\x07ok\n", want: []string{"red", "ok"}},
+		{name: "tabs expanded", output: "a\tb\n", want: []string{"a    b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tailLines([]byte(tt.output), 2); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tailLines(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+}
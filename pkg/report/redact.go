@@ -0,0 +1,204 @@
+package report
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// redactedMark replaces every secret a Redactor finds.
+const redactedMark = "[REDACTED]"
+
+// minSecretLen is the shortest environment value treated as a secret; shorter
+// values ("1", "true") would mask unrelated text all over a report.
+const minSecretLen = 6
+
+// DefaultSecretEnv lists the glob patterns of environment variable names whose
+// values are always redacted.
+var DefaultSecretEnv = []string{
+	"GITHUB_PAT*",
+	"GITHUB_TOKEN",
+	"GH_TOKEN",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"*_TOKEN",
+	"*_SECRET",
+	"*_PASSWORD",
+	"*_API_KEY",
+}
+
+// defaultSecretPatterns match common credentials wherever they appear. When a
+// pattern has a capture group, only the first group is masked, so the text
+// around it keeps the output readable.
+var defaultSecretPatterns = []string{
+	// AWS access key IDs
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// AWS secret access keys assigned in config files and env dumps
+	`(?i)aws_secret_access_key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`,
+	// HTTP bearer tokens
+	`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`,
+	// GitHub tokens
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	`\bgithub_pat_[A-Za-z0-9_]{22,}\b`,
+	// Slack tokens
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}`,
+	// PEM private keys; the BEGIN and END lines are kept
+	`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----(.+?)-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+// Redactor masks secrets in report results: the values of secret environment
+// variables, and text matching credential patterns.
+type Redactor struct {
+	values   []string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor returns a Redactor that masks the values in environ (KEY=value
+// pairs, as from os.Environ) of the variables whose names match one of the
+// globs in secretEnv, and the matches of the built-in credential patterns and
+// of patterns. Values shorter than minSecretLen are ignored.
+func NewRedactor(environ, secretEnv, patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || len(value) < minSecretLen {
+			continue
+		}
+		for _, glob := range secretEnv {
+			if matched, err := path.Match(glob, name); err != nil {
+				return nil, fmt.Errorf("invalid secret variable pattern %q: %w", glob, err)
+			} else if matched {
+				r.values = append(r.values, value)
+				break
+			}
+		}
+	}
+
+	// Longer values first, so a secret containing another is masked whole.
+	slices.SortFunc(r.values, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return strings.Compare(a, b)
+	})
+	r.values = slices.Compact(r.values)
+
+	for _, p := range append(slices.Clone(defaultSecretPatterns), patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// Redact masks the secrets in s and returns the result with the number of
+// secrets masked. A nil Redactor returns s unchanged.
+func (r *Redactor) Redact(s string) (string, int) {
+	if r == nil || s == "" {
+		return s, 0
+	}
+
+	n := 0
+	for _, v := range r.values {
+		if c := strings.Count(s, v); c > 0 {
+			n += c
+			s = strings.ReplaceAll(s, v, redactedMark)
+		}
+	}
+
+	for _, re := range r.patterns {
+		var c int
+		s, c = redactPattern(re, s)
+		n += c
+	}
+
+	return s, n
+}
+
+// redactPattern masks each match of re in s, or only its first capture group
+// when it has one, and returns the result with the number of matches.
+func redactPattern(re *regexp.Regexp, s string) (string, int) {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, 0
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(redactedMark)
+		last = end
+	}
+	b.WriteString(s[last:])
+
+	return b.String(), len(matches)
+}
+
+// RedactResult returns res with its command, description, and every output
+// masked, and Redactions set to the number of secrets found in the command,
+// the description, and the combined output. Stdout, Stderr, and attempt
+// outputs repeat the combined output, so they are masked but not counted.
+func (r *Redactor) RedactResult(res Result) Result {
+	if r == nil {
+		return res
+	}
+
+	var n, count int
+	res.Action.Command, count = r.Redact(res.Action.Command)
+	n += count
+	res.Action.Description, count = r.Redact(res.Action.Description)
+	n += count
+	res.Output, count = r.Redact(res.Output)
+	n += count
+
+	res.Stdout, _ = r.Redact(res.Stdout)
+	res.Stderr, _ = r.Redact(res.Stderr)
+
+	if len(res.Attempts) > 0 {
+		attempts := slices.Clone(res.Attempts)
+		for i := range attempts {
+			attempts[i].Output, _ = r.Redact(attempts[i].Output)
+		}
+		res.Attempts = attempts
+	}
+
+	res.Redactions = n
+	return res
+}
+
+// RedactResults applies RedactResult to every result.
+func (r *Redactor) RedactResults(results []Result) []Result {
+	out := make([]Result, len(results))
+	for i, res := range results {
+		out[i] = r.RedactResult(res)
+	}
+	return out
+}
+
+// redactionSummary describes n masked secrets, e.g. "3 secrets redacted".
+func redactionSummary(n int) string {
+	if n == 1 {
+		return "1 secret redacted"
+	}
+	return fmt.Sprintf("%d secrets redacted", n)
+}
+
+// CountRedactions returns the total number of secrets masked in results.
+func CountRedactions(results []Result) int {
+	n := 0
+	for _, r := range results {
+		n += r.Redactions
+	}
+	return n
+}
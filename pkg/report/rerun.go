@@ -0,0 +1,81 @@
+package report
+
+import "strings"
+
+// RerunGroup lists saved results to run again on one machine.
+type RerunGroup struct {
+	// Host is the remote host the results ran on, or "" for this machine.
+	Host string
+	// Indices are positions in the saved results, in report order.
+	Indices []int
+}
+
+// PlanRerun picks the saved results to run again: every result, or with
+// failedOnly those that failed the run or were skipped because a need did.
+// They are grouped by the host they ran on, in the order hosts first appear.
+// Results whose command was redacted cannot run again and are returned in
+// redacted instead.
+func PlanRerun(results []Result, failedOnly bool) (groups []RerunGroup, redacted []int) {
+	byHost := map[string]int{}
+
+	for i, r := range results {
+		if failedOnly && !r.Failed() && !r.Skipped {
+			continue
+		}
+		if strings.Contains(r.Action.Command, redactedMark) {
+			redacted = append(redacted, i)
+			continue
+		}
+
+		// Local results record the shell that ran them; remote ones only
+		// their host.
+		host := ""
+		if r.Shell == "" {
+			host = r.Hostname
+		}
+
+		g, ok := byHost[host]
+		if !ok {
+			g = len(groups)
+			byHost[host] = g
+			groups = append(groups, RerunGroup{Host: host})
+		}
+		groups[g].Indices = append(groups[g].Indices, i)
+	}
+
+	return groups, redacted
+}
+
+// RerunAction returns the action of a saved result, set to run in the
+// directory it ran in before.
+func RerunAction(r Result) Action {
+	action := r.Action
+	if action.Dir == "" {
+		action.Dir = r.Dir
+	}
+	return action
+}
+
+// Record returns an ExecConfig.OnResult callback for running the group's
+// actions, in Indices order, that stores each result in fresh under the
+// index of the saved result its action came from. ExecuteActions leaves out
+// actions that never started, so its results cannot be matched to the group
+// by position.
+func (g RerunGroup) Record(fresh map[int]Result) func(index int, result Result) {
+	return func(index int, result Result) {
+		fresh[g.Indices[index]] = result
+	}
+}
+
+// MergeResults returns saved with each result replaced by the fresh result
+// at the same index.
+func MergeResults(saved []Result, fresh map[int]Result) []Result {
+	merged := make([]Result, len(saved))
+	for i, r := range saved {
+		if f, ok := fresh[i]; ok {
+			r = f
+		}
+		merged[i] = r
+	}
+	return merged
+}
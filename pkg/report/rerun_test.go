@@ -0,0 +1,124 @@
+package report
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlanRerun(t *testing.T) {
+	results := []Result{
+		{Action: Action{Command: "ok"}, Shell: "/bin/sh", Hostname: "laptop"},
+		{Action: Action{Command: "broken"}, ExitCode: 1, Shell: "/bin/sh", Hostname: "laptop"},
+		{Action: Action{Command: "remote"}, ExitCode: 2, Hostname: "web1"},
+		{Action: Action{Command: "after"}, Skipped: true, Shell: "/bin/sh", Hostname: "laptop"},
+		{Action: Action{Command: "curl -H 'X: [REDACTED]'"}, ExitCode: 1, Shell: "/bin/sh"},
+		{Action: Action{Command: "flaky", AllowFailure: true}, ExitCode: 1, Shell: "/bin/sh"},
+	}
+
+	tests := []struct {
+		name         string
+		failedOnly   bool
+		wantGroups   []RerunGroup
+		wantRedacted []int
+	}{
+		{
+			name:       "failed only",
+			failedOnly: true,
+			wantGroups: []RerunGroup{
+				{Indices: []int{1, 3}},
+				{Host: "web1", Indices: []int{2}},
+			},
+			wantRedacted: []int{4},
+		},
+		{
+			name: "everything",
+			wantGroups: []RerunGroup{
+				{Indices: []int{0, 1, 3, 5}},
+				{Host: "web1", Indices: []int{2}},
+			},
+			wantRedacted: []int{4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, redacted := PlanRerun(results, tt.failedOnly)
+			if !reflect.DeepEqual(groups, tt.wantGroups) {
+				t.Errorf("groups = %+v, want %+v", groups, tt.wantGroups)
+			}
+			if !reflect.DeepEqual(redacted, tt.wantRedacted) {
+				t.Errorf("redacted = %v, want %v", redacted, tt.wantRedacted)
+			}
+		})
+	}
+}
+
+func TestRerunAction(t *testing.T) {
+	if got := RerunAction(Result{Action: Action{Command: "ls"}, Dir: "/srv"}); got.Dir != "/srv" {
+		t.Errorf("Dir = %q, want the directory the result ran in", got.Dir)
+	}
+	if got := RerunAction(Result{Action: Action{Command: "ls", Dir: "sub"}, Dir: "/srv/sub"}); got.Dir != "sub" {
+		t.Errorf("Dir = %q, want the action's own @cwd", got.Dir)
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	saved := []Result{
+		{Action: Action{Command: "a"}},
+		{Action: Action{Command: "b"}, ExitCode: 1},
+		{Action: Action{Command: "c"}},
+	}
+
+	got := MergeResults(saved, map[int]Result{1: {Action: Action{Command: "b"}}})
+	if got[1].ExitCode != 0 || got[0].Action.Command != "a" || got[2].Action.Command != "c" {
+		t.Errorf("MergeResults() = %+v, want only the second result replaced", got)
+	}
+	if saved[1].ExitCode != 1 {
+		t.Error("MergeResults() modified its input")
+	}
+}
+
+func TestRerunGroupRecordCancelled(t *testing.T) {
+	saved := []Result{
+		{Action: Action{Command: "exit 1"}, ExitCode: 1, Shell: "/bin/sh"},
+		{Action: Action{Command: "true"}, Shell: "/bin/sh"},
+		{Action: Action{Command: "sleep 5"}, ExitCode: 1, Shell: "/bin/sh"},
+		{Action: Action{Command: "exit 3"}, ExitCode: 3, Shell: "/bin/sh"},
+	}
+
+	groups, _ := PlanRerun(saved, true)
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(groups))
+	}
+	group := groups[0]
+
+	actions := make([]Action, len(group.Indices))
+	for k, i := range group.Indices {
+		actions[k] = RerunAction(saved[i])
+	}
+	actions[0].Command = "exit 0"
+
+	// Cancel while "sleep 5" runs, so "exit 3" never starts.
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	fresh := map[int]Result{}
+	ExecuteActions(ctx, actions, ExecConfig{Shell: "/bin/sh", Jobs: 1, OnResult: group.Record(fresh)})
+
+	if len(fresh) != 2 {
+		t.Fatalf("recorded %d results, want 2: %+v", len(fresh), fresh)
+	}
+
+	merged := MergeResults(saved, fresh)
+	if merged[0].ExitCode != 0 {
+		t.Errorf("merged[0].ExitCode = %d, want the fresh result", merged[0].ExitCode)
+	}
+	if merged[2].ExitCode != -1 {
+		t.Errorf("merged[2].ExitCode = %d, want the killed fresh result", merged[2].ExitCode)
+	}
+	if merged[3].ExitCode != 3 || merged[3].Action.Command != "exit 3" {
+		t.Errorf("merged[3] = %+v, want the saved result of the action that never ran", merged[3])
+	}
+}
@@ -0,0 +1,170 @@
+package report
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cloudbridgeuy/scripts/pkg/markdown"
+)
+
+// shellLanguages are the code-fence languages treated as runnable commands.
+var shellLanguages = map[string]bool{"sh": true, "bash": true, "zsh": true, "shell": true}
+
+// noRunAttr marks a shell fence that should be shown but never executed,
+// e.g. "```sh norun".
+const noRunAttr = "norun"
+
+// ParseMarkdownActions turns the shell code fences of a Markdown runbook into
+// Actions, in document order.
+//
+// Rules:
+//  1. Only fences tagged sh, bash, zsh, or shell become actions; fences with a "norun" attribute are skipped
+//  2. The description is the paragraph directly before the fence, or else the nearest heading above it
+//  3. Leading "# @directive" lines inside the fence configure the action, as in ParseActions, and are
+//     removed from the command; errors name the runbook line
+//  4. The rest of the fence is the command, verbatim; fences left empty are skipped
+//  5. "# @name" and "# @needs" declare a dependency graph, validated as in ParseActions
+func ParseMarkdownActions(src []byte) ([]Action, error) {
+	body := markdown.StripFrontmatter(src)
+	lineOffset := strings.Count(string(src[:len(src)-len(body)]), "\n")
+
+	actions := []Action{}
+
+	for _, block := range markdown.ExtractCodeBlocks(body) {
+		if !isRunnable(block) {
+			continue
+		}
+
+		action := Action{Description: block.Paragraph, Line: lineOffset + block.Line}
+		if action.Description == "" {
+			action.Description = block.Heading
+		}
+
+		lines := strings.Split(strings.TrimRight(block.Code, "\n"), "\n")
+		n := 0
+		for ; n < len(lines); n++ {
+			comment, ok := strings.CutPrefix(strings.TrimSpace(lines[n]), "#")
+			comment = strings.TrimSpace(comment)
+			if !ok || !isDirective(comment) {
+				break
+			}
+			if err := applyDirective(&action, comment, lineOffset+block.Line+1+n); err != nil {
+				return nil, err
+			}
+		}
+
+		action.Command = strings.Join(lines[n:], "\n")
+		if strings.TrimSpace(action.Command) == "" {
+			continue
+		}
+
+		actions = append(actions, action)
+	}
+
+	if err := checkNeeds(actions); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
+
+// isRunnable reports whether a code block should become an action.
+func isRunnable(block markdown.CodeBlock) bool {
+	return shellLanguages[block.Language] && !slices.Contains(block.Attrs, noRunAttr)
+}
+
+// AnnotateMarkdown returns the runbook src with each executed fence followed by
+// a status badge and the action's output, so the result reads as a record of
+// the run. Results are matched to fences by Action.Line; fences that did not
+// run are left untouched, and src is otherwise reproduced byte for byte,
+// except that redactor masks the secrets in it, fence bodies included. The
+// results are expected to be redacted already; a nil redactor masks nothing.
+func AnnotateMarkdown(src []byte, results []Result, redactor *Redactor) string {
+	body := markdown.StripFrontmatter(src)
+	byteOffset := len(src) - len(body)
+	lineOffset := strings.Count(string(src[:byteOffset]), "\n")
+
+	// A fence with a @matrix ran once per expansion; each run gets its own
+	// badge.
+	byLine := make(map[int][]Result, len(results))
+	for _, r := range results {
+		if r.Action.Line > 0 {
+			byLine[r.Action.Line] = append(byLine[r.Action.Line], r)
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+
+	// The source is masked in the pieces between annotations, so offsets
+	// still refer to the unmasked src.
+	copySource := func(from, to int) {
+		masked, _ := redactor.Redact(string(src[from:to]))
+		b.WriteString(masked)
+	}
+
+	for _, block := range markdown.ExtractCodeBlocks(body) {
+		runs, ok := byLine[lineOffset+block.Line]
+		if !ok {
+			continue
+		}
+
+		end := byteOffset + block.End
+		copySource(last, end)
+		if end > 0 && src[end-1] != '\n' {
+			b.WriteString("\n")
+		}
+		for _, r := range runs {
+			b.WriteString(annotation(r))
+		}
+		last = end
+	}
+
+	copySource(last, len(src))
+	return b.String()
+}
+
+// annotation renders the badge and output block inserted after a fence, e.g.
+//
+//	> ✅ **passed** · exit 0 · 12ms
+func annotation(r Result) string {
+	var b strings.Builder
+
+	matrix := ""
+	if len(r.Action.MatrixValues) > 0 {
+		matrix = " · " + strings.Join(r.Action.MatrixValues, ", ")
+	}
+
+	if r.Skipped {
+		return fmt.Sprintf("\n> ⏭️ **skipped**%s · needs %s\n", matrix, strings.Join(r.BlockedBy, ", "))
+	}
+
+	badge := "✅ **passed**"
+	switch {
+	case r.Failed():
+		badge = "❌ **failed**"
+	case !r.Passed():
+		badge = "⚠️ **failed**"
+	}
+
+	fmt.Fprintf(&b, "\n> %s%s · exit %d · %s", badge, matrix, r.ExitCode, formatDuration(r.Duration))
+	if note := statusNote(r); note != "" {
+		fmt.Fprintf(&b, " (%s)", note)
+	}
+	b.WriteString("\n")
+
+	if failed := r.FailedAssertions(); len(failed) > 0 {
+		b.WriteString(">\n")
+		for _, a := range failed {
+			fmt.Fprintf(&b, "> - assertion failed: %s\n", a)
+		}
+	}
+
+	if output := strings.TrimRight(r.Output, "\n"); output != "" {
+		fence := codeFence(output)
+		fmt.Fprintf(&b, "\n%stext\n%s\n%s\n", fence, output, fence)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,240 @@
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMarkdownActions(t *testing.T) {
+	src := strings.Join([]string{
+		"---",
+		"title: Deploy runbook",
+		"---",
+		"# Deploy",
+		"",
+		"## Cluster reachable",
+		"",
+		"```sh",
+		"kubectl cluster-info",
+		"```",
+		"",
+		"Show the pods in the",
+		"`web` namespace:",
+		"",
+		"```bash",
+		"# @timeout 30s",
+		"# @tag smoke",
+		"kubectl get pods -n web",
+		"# a shell comment stays",
+		"```",
+		"",
+		"```sh norun",
+		"kubectl delete ns web",
+		"```",
+		"",
+		"```yaml",
+		"kind: Pod",
+		"```",
+		"",
+		"```",
+		"echo untagged",
+		"```",
+	}, "\n")
+
+	got, err := ParseMarkdownActions([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseMarkdownActions() returned unexpected error: %v", err)
+	}
+
+	want := []Action{
+		{Description: "Cluster reachable", Command: "kubectl cluster-info", Line: 8},
+		{
+			Description: "Show the pods in the `web` namespace:",
+			Command:     "kubectl get pods -n web\n# a shell comment stays",
+			Timeout:     30 * time.Second,
+			Tags:        []string{"smoke"},
+			Line:        15,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseMarkdownActions() =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestParseMarkdownActionsDirectiveError(t *testing.T) {
+	src := "---\ntitle: x\n---\n# Runbook\n\n```sh\n# @tag ok\n# @bogus\nls\n```\n"
+
+	_, err := ParseMarkdownActions([]byte(src))
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	if !strings.Contains(err.Error(), `line 8: unknown directive "@bogus"`) {
+		t.Errorf("error = %q, want it to name runbook line 8", err.Error())
+	}
+}
+
+func TestParseMarkdownActionsSkipsEmptyFences(t *testing.T) {
+	got, err := ParseMarkdownActions([]byte("# Runbook\n\n```sh\n# @tag only\n```\n"))
+	if err != nil {
+		t.Fatalf("ParseMarkdownActions() returned unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no actions, got %#v", got)
+	}
+}
+
+func TestAnnotateMarkdown(t *testing.T) {
+	src := strings.Join([]string{
+		"---",
+		"title: Deploy runbook",
+		"---",
+		"# Deploy",
+		"",
+		"```sh",
+		"uname",
+		"```",
+		"",
+		"```sh norun",
+		"rm -rf /",
+		"```",
+		"",
+		"```sh",
+		"false",
+		"```",
+		"",
+		"```sh",
+		"quiet",
+		"```",
+		"",
+		"Done.",
+		"",
+	}, "\n")
+
+	results := []Result{
+		{Action: Action{Command: "uname", Line: 6}, Output: "Linux\n", Duration: 5 * time.Millisecond},
+		{
+			Action:   Action{Command: "false", Line: 14, Assertions: []Assertion{{Kind: OutputContains, Pattern: "ok"}}},
+			ExitCode: 1,
+			Output:   "has ``` inside",
+			Assertions: []AssertionResult{
+				{Assertion: Assertion{Kind: OutputContains, Pattern: "ok"}},
+			},
+		},
+	}
+
+	want := strings.Join([]string{
+		"---",
+		"title: Deploy runbook",
+		"---",
+		"# Deploy",
+		"",
+		"```sh",
+		"uname",
+		"```",
+		"",
+		"> ✅ **passed** · exit 0 · 5ms",
+		"",
+		"```text",
+		"Linux",
+		"```",
+		"",
+		"```sh norun",
+		"rm -rf /",
+		"```",
+		"",
+		"```sh",
+		"false",
+		"```",
+		"",
+		"> ❌ **failed** · exit 1 · 0s",
+		">",
+		`> - assertion failed: output-contains "ok"`,
+		"",
+		"````text",
+		"has ``` inside",
+		"````",
+		"",
+		"```sh",
+		"quiet",
+		"```",
+		"",
+		"Done.",
+		"",
+	}, "\n")
+
+	if got := AnnotateMarkdown([]byte(src), results, nil); got != want {
+		t.Errorf("AnnotateMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAnnotateMarkdownMatrix(t *testing.T) {
+	src := "```sh\nping {{ .host }}\n```\n"
+
+	results := []Result{
+		{Action: Action{Command: "ping db1", Line: 1, MatrixValues: []string{"host=db1"}}},
+		{Action: Action{Command: "ping db2", Line: 1, MatrixValues: []string{"host=db2"}}, Skipped: true, BlockedBy: []string{"vpn"}},
+	}
+
+	want := src + "\n> ✅ **passed** · host=db1 · exit 0 · 0s\n" + "\n> ⏭️ **skipped** · host=db2 · needs vpn\n"
+	if got := AnnotateMarkdown([]byte(src), results, nil); got != want {
+		t.Errorf("AnnotateMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAnnotateMarkdownUnterminatedFence(t *testing.T) {
+	src := "# Runbook\n\n```sh\necho hi"
+	results := []Result{{Action: Action{Command: "echo hi", Line: 3}, Output: "hi\n"}}
+
+	got := AnnotateMarkdown([]byte(src), results, nil)
+	want := "# Runbook\n\n```sh\necho hi\n\n> ✅ **passed** · exit 0 · 0s\n\n```text\nhi\n```\n"
+	if got != want {
+		t.Errorf("AnnotateMarkdown() = %q, want %q", got, want)
+	}
+}
+
+func TestAnnotateMarkdownRoundTrip(t *testing.T) {
+	src := []byte("# Runbook\n\n```sh\necho one\n```\n\n```bash\necho two\n```\n")
+
+	actions, err := ParseMarkdownActions(src)
+	if err != nil {
+		t.Fatalf("ParseMarkdownActions() returned unexpected error: %v", err)
+	}
+	results := make([]Result, len(actions))
+	for i, a := range actions {
+		results[i] = Result{Action: a, Output: a.Command[len("echo "):] + "\n"}
+	}
+
+	got := AnnotateMarkdown(src, results, nil)
+	for _, s := range []string{"```\n\n> ✅ **passed** · exit 0 · 0s\n\n```text\none\n```\n", "```text\ntwo\n```\n"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("AnnotateMarkdown() output missing %q:\n%s", s, got)
+		}
+	}
+}
+
+func TestAnnotateMarkdownRedacts(t *testing.T) {
+	src := "# Runbook\n\nUse the token s3cr3t-value.\n\n```sh\ncurl -H \"Authorization: Bearer abcdefghijklmnop123\" $URL\n```\n\n```sh norun\necho s3cr3t-value\n```\n"
+
+	redactor, err := NewRedactor([]string{"DEPLOY_TOKEN=s3cr3t-value"}, DefaultSecretEnv, nil)
+	if err != nil {
+		t.Fatalf("NewRedactor() returned unexpected error: %v", err)
+	}
+
+	results := redactor.RedactResults([]Result{
+		{Action: Action{Command: "curl -H \"Authorization: Bearer abcdefghijklmnop123\" $URL", Line: 5}, Output: "ok\n"},
+	})
+
+	got := AnnotateMarkdown([]byte(src), results, redactor)
+	for _, secret := range []string{"abcdefghijklmnop123", "s3cr3t-value"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("AnnotateMarkdown() output contains the secret %q:\n%s", secret, got)
+		}
+	}
+	for _, s := range []string{"Authorization: Bearer [REDACTED]", "Use the token [REDACTED].", "> ✅ **passed**", "```text\nok\n```"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("AnnotateMarkdown() output missing %q:\n%s", s, got)
+		}
+	}
+}
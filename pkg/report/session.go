@@ -0,0 +1,305 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shellSession runs actions one after another inside a single long-lived
+// shell, so that cd, exported variables, and functions defined by one action
+// are seen by the next. Each command is followed by a sentinel marker on both
+// stdout and stderr; the marker on stdout carries the command's exit status,
+// and everything written before the markers is the command's output.
+//
+// The marker on stdout also carries the shell's working directory, so the
+// next action's Result.Dir follows any cd.
+//
+// When the shell dies (a command ran exit, or was killed for its timeout) the
+// next action starts a fresh shell, without the state of the previous one.
+type shellSession struct {
+	shell  string
+	marker string
+	dir    string
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	cancel context.CancelFunc
+	stdout *markerWriter
+	stderr *markerWriter
+	exited chan struct{}
+}
+
+// newShellSession returns a session for shell. The shell itself is started
+// lazily, by the first action. The marker is random so that no command
+// output can plausibly contain it.
+func newShellSession(shell string) *shellSession {
+	return &shellSession{shell: shell, marker: fmt.Sprintf("__scripts_session_%016x__", rand.Uint64())}
+}
+
+// start launches the shell, reading commands from a pipe on its stdin.
+func (s *shellSession) start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cmd := exec.CommandContext(ctx, s.shell, "-s")
+	setProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+
+	token := "\n" + s.marker + " "
+	stdout, stderr := newMarkerWriter(token), newMarkerWriter(token)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return fmt.Errorf("session stdin: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("starting session shell: %w", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		stdout.flush()
+		stderr.flush()
+		close(exited)
+	}()
+
+	s.cmd, s.stdin, s.cancel = cmd, stdin, cancel
+	s.stdout, s.stderr, s.exited = stdout, stderr, exited
+	return nil
+}
+
+// run executes one attempt of action in the session. It has the same
+// signature as runAction.
+func (s *shellSession) run(ctx context.Context, action Action, cfg ExecConfig, onOutput func([]byte)) Result {
+	if timeout := cfg.EffectiveTimeout(action); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	maxBytes, maxLines := cfg.EffectiveOutputLimits(action)
+	capture := newOutputCapture(maxBytes, maxLines, action.Assertions, onOutput)
+
+	dir := workDir(s.dir, action.Dir)
+
+	start := time.Now()
+	code, err := s.exec(ctx, action, capture)
+	end := time.Now()
+
+	if err != nil {
+		fmt.Fprintln(capture.Stderr(), err)
+	}
+
+	timedOut := code == -1 && errors.Is(ctx.Err(), context.DeadlineExceeded)
+	result := newResult(action, capture, code, timedOut, start, end)
+	result.Dir = dir
+	return result
+}
+
+// exec sends action to the shell, starting one if needed, and waits for its
+// markers. It returns the command's exit status, or -1 when ctx ended first
+// and the shell was killed.
+func (s *shellSession) exec(ctx context.Context, action Action, capture *outputCapture) (int, error) {
+	// A relative @cwd is resolved by the shell, from wherever earlier
+	// actions left it.
+	if action.Dir != "" {
+		if err := checkDir(workDir(s.dir, action.Dir)); err != nil {
+			return 1, err
+		}
+	}
+
+	// An unterminated quote or compound command would swallow the markers
+	// and every later command, so reject syntax errors before sending.
+	if out, err := exec.CommandContext(ctx, s.shell, "-n", "-c", action.Command).CombinedOutput(); err != nil {
+		capture.Stderr().Write(out)
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return 1, err
+		}
+		return exitCode(err), nil
+	}
+
+	if s.cmd == nil {
+		if err := s.start(); err != nil {
+			return 1, err
+		}
+	}
+
+	s.stdout.route(capture.Stdout())
+	s.stderr.route(capture.Stderr())
+	defer s.stdout.route(nil)
+	defer s.stderr.route(nil)
+
+	// A failed write means the shell is gone; the exited case below reports it.
+	_, _ = io.WriteString(s.stdin, s.script(action))
+
+	var mark string
+	for gotStdout, gotStderr := false, false; !gotStdout || !gotStderr; {
+		select {
+		case mark = <-s.stdout.marks:
+			gotStdout = true
+		case <-s.stderr.marks:
+			gotStderr = true
+		case <-s.exited:
+			code := s.cmd.ProcessState.ExitCode()
+			s.reset()
+			return code, nil
+		case <-ctx.Done():
+			s.kill()
+			return -1, nil
+		}
+	}
+
+	status, dir, _ := strings.Cut(mark, " ")
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return 1, fmt.Errorf("session: invalid exit status %q", status)
+	}
+	s.dir = dir
+	return code, nil
+}
+
+// script wraps action's command so the shell prints the markers after it,
+// the one on stdout followed by the exit status and working directory.
+// The command reads from /dev/null rather than the shell's own input, and
+// runs in a subshell when it sets a working directory or environment, so
+// those stay local to the action.
+func (s *shellSession) script(action Action) string {
+	command := action.Command
+	if action.Dir != "" || len(action.Env) > 0 {
+		command = "(\n" + remoteScript(action) + "\n)"
+	}
+
+	return fmt.Sprintf("{\n%s\n} </dev/null\nprintf '\\n%s %%d %%s\\n' \"$?\" \"$PWD\"\nprintf '\\n%s \\n' >&2\n",
+		command, s.marker, s.marker)
+}
+
+// kill ends the shell and every process it started.
+func (s *shellSession) kill() {
+	s.cancel()
+	<-s.exited
+	s.reset()
+}
+
+// reset forgets a shell that has exited, closing its input pipe, so the next
+// action starts a new one in the current directory of this process.
+func (s *shellSession) reset() {
+	s.cancel()
+	s.stdin.Close()
+	s.cmd = nil
+	s.dir = ""
+}
+
+// close lets the shell exit by closing its input, killing it if it has not
+// exited within waitDelay.
+func (s *shellSession) close() {
+	if s.cmd == nil {
+		return
+	}
+
+	s.stdin.Close()
+	select {
+	case <-s.exited:
+		s.reset()
+	case <-time.After(waitDelay):
+		s.kill()
+	}
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// markerWriter receives one output stream of a session shell. It forwards
+// what it is written to its current destination and, whenever the stream
+// contains token followed by the rest of a line, sends that rest on marks
+// instead. Bytes that could be the beginning of a token are held back until
+// the next write tells them apart.
+type markerWriter struct {
+	token []byte
+	marks chan string
+
+	mu   sync.Mutex
+	dest io.Writer
+	buf  []byte
+}
+
+func newMarkerWriter(token string) *markerWriter {
+	return &markerWriter{token: []byte(token), marks: make(chan string, 1)}
+}
+
+// route sets where output goes from now on; nil discards it.
+func (w *markerWriter) route(dest io.Writer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dest = dest
+}
+
+func (w *markerWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.Index(w.buf, w.token)
+		if i < 0 {
+			break
+		}
+		rest := w.buf[i+len(w.token):]
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			w.emit(w.buf[:i])
+			w.buf = append([]byte(nil), w.buf[i:]...)
+			return len(p), nil
+		}
+
+		w.emit(w.buf[:i])
+		w.marks <- string(rest[:end])
+		w.buf = append([]byte(nil), rest[end+1:]...)
+	}
+
+	keep := partialToken(w.buf, w.token)
+	w.emit(w.buf[:len(w.buf)-keep])
+	w.buf = append([]byte(nil), w.buf[len(w.buf)-keep:]...)
+	return len(p), nil
+}
+
+// flush forwards whatever is still held back.
+func (w *markerWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.emit(w.buf)
+	w.buf = nil
+}
+
+func (w *markerWriter) emit(p []byte) {
+	if w.dest != nil && len(p) > 0 {
+		w.dest.Write(p)
+	}
+}
+
+// partialToken returns the length of the longest suffix of buf that is a
+// proper prefix of token.
+func partialToken(buf, token []byte) int {
+	for n := min(len(buf), len(token)-1); n > 0; n-- {
+		if bytes.HasSuffix(buf, token[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteActionsSession(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		actions []Action
+		verify  func(t *testing.T, got []Result)
+	}{
+		{
+			name: "state carries over between actions",
+			actions: []Action{
+				{Command: "cd " + dir},
+				{Command: "export GREETING=hello"},
+				{Command: "greet() { echo \"$GREETING from $(pwd)\"; }"},
+				{Command: "greet"},
+			},
+			verify: func(t *testing.T, got []Result) {
+				if want := "hello from " + dir + "\n"; got[3].Output != want {
+					t.Errorf("Output = %q, want %q", got[3].Output, want)
+				}
+				if got[3].Dir != dir {
+					t.Errorf("Dir = %q, want the directory the session moved to", got[3].Dir)
+				}
+			},
+		},
+		{
+			name: "exit status and streams are kept per action",
+			actions: []Action{
+				{Command: "echo out; echo err >&2; exit_code() { return 3; }; exit_code"},
+				{Command: "printf 'no newline'"},
+			},
+			verify: func(t *testing.T, got []Result) {
+				if got[0].ExitCode != 3 || got[0].Stdout != "out\n" || got[0].Stderr != "err\n" {
+					t.Errorf("result[0] = exit %d, stdout %q, stderr %q; want 3, %q, %q", got[0].ExitCode, got[0].Stdout, got[0].Stderr, "out\n", "err\n")
+				}
+				if got[1].Output != "no newline" {
+					t.Errorf("result[1].Output = %q, want %q", got[1].Output, "no newline")
+				}
+			},
+		},
+		{
+			name: "exit starts a fresh shell",
+			actions: []Action{
+				{Command: "export KEPT=yes"},
+				{Command: "echo bye; exit 4"},
+				{Command: "echo \"[$KEPT]\""},
+			},
+			verify: func(t *testing.T, got []Result) {
+				if got[1].ExitCode != 4 || got[1].Output != "bye\n" {
+					t.Errorf("result[1] = exit %d, output %q; want 4 and %q", got[1].ExitCode, got[1].Output, "bye\n")
+				}
+				if got[2].Output != "[]\n" {
+					t.Errorf("result[2].Output = %q, want the state of a fresh shell", got[2].Output)
+				}
+			},
+		},
+		{
+			name: "timeout kills the shell",
+			actions: []Action{
+				{Command: "sleep 5", Timeout: 200 * time.Millisecond},
+				{Command: "echo after"},
+			},
+			verify: func(t *testing.T, got []Result) {
+				if !got[0].TimedOut || got[0].ExitCode != -1 {
+					t.Errorf("result[0] = exit %d, timed out %v; want -1 and true", got[0].ExitCode, got[0].TimedOut)
+				}
+				if got[1].Output != "after\n" {
+					t.Errorf("result[1].Output = %q, want %q", got[1].Output, "after\n")
+				}
+			},
+		},
+		{
+			name: "syntax error does not swallow later actions",
+			actions: []Action{
+				{Command: `echo "unterminated`},
+				{Command: "echo fine"},
+			},
+			verify: func(t *testing.T, got []Result) {
+				if got[0].ExitCode == 0 || got[0].Stderr == "" {
+					t.Errorf("result[0] = exit %d, stderr %q; want a syntax error", got[0].ExitCode, got[0].Stderr)
+				}
+				if got[1].Output != "fine\n" {
+					t.Errorf("result[1].Output = %q, want %q", got[1].Output, "fine\n")
+				}
+			},
+		},
+		{
+			name: "relative cwd follows the session's directory",
+			actions: []Action{
+				{Command: "mkdir -p repo/sub && cd repo", Dir: dir},
+				{Command: "cd " + dir + "/repo"},
+				{Command: "pwd", Dir: "sub"},
+				{Command: "pwd", Dir: "./missing"},
+			},
+			verify: func(t *testing.T, got []Result) {
+				if want := dir + "/repo/sub"; got[2].ExitCode != 0 || got[2].Output != want+"\n" || got[2].Dir != want {
+					t.Errorf("result[2] = exit %d, output %q, dir %q; want 0 and %q", got[2].ExitCode, got[2].Output, got[2].Dir, want)
+				}
+				if got[3].ExitCode == 0 || !strings.Contains(got[3].Output, dir+"/repo/missing") {
+					t.Errorf("result[3] = exit %d, output %q; want a missing directory under the session's", got[3].ExitCode, got[3].Output)
+				}
+			},
+		},
+		{
+			name: "cwd and env stay local to their action",
+			actions: []Action{
+				{Command: "pwd; echo $LOCAL", Dir: dir, Env: []string{"LOCAL=it's local"}},
+				{Command: "echo \"[$LOCAL]\"; pwd"},
+			},
+			verify: func(t *testing.T, got []Result) {
+				if want := dir + "\nit's local\n"; got[0].Output != want {
+					t.Errorf("result[0].Output = %q, want %q", got[0].Output, want)
+				}
+				if strings.HasPrefix(got[1].Output, "[it's local]") || strings.Contains(got[1].Output, dir) {
+					t.Errorf("result[1].Output = %q, want @cwd and @env not to leak", got[1].Output)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExecuteActions(context.Background(), tt.actions, ExecConfig{Shell: "/bin/sh", Session: true, Jobs: 4})
+			if len(got) != len(tt.actions) {
+				t.Fatalf("got %d results, want %d", len(got), len(tt.actions))
+			}
+			tt.verify(t, got)
+		})
+	}
+}
+
+func TestExecuteActionsSessionClosesExitedShells(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("counts open descriptors through /proc")
+	}
+
+	openFiles := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+
+	actions := make([]Action, 20)
+	for i := range actions {
+		actions[i] = Action{Command: "exit 1"}
+	}
+
+	before := openFiles()
+	ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Session: true})
+	if after := openFiles(); after > before {
+		t.Errorf("open descriptors went from %d to %d, want every exited shell's input closed", before, after)
+	}
+}
+
+func TestMarkerWriter(t *testing.T) {
+	const token = "\n__mark__ "
+
+	var out bytes.Buffer
+	w := newMarkerWriter(token)
+	w.route(&out)
+
+	// The marker arrives split across writes, and output that merely starts
+	// like it is passed through.
+	for _, chunk := range []string{"line\n", "\n__ma", "rk", "__ 7", "\nnext\n__m", "ore\n"} {
+		w.Write([]byte(chunk))
+	}
+
+	select {
+	case status := <-w.marks:
+		if status != "7" {
+			t.Errorf("status = %q, want %q", status, "7")
+		}
+	default:
+		t.Fatal("expected a marker")
+	}
+
+	w.flush()
+	if want := "line\nnext\n__more\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
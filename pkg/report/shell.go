@@ -0,0 +1,156 @@
+package report
+
+import (
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// heredoc is a here-document opened on a command line, e.g. "<<EOF" or
+// "<<-'EOF'". StripTabs is set for the "<<-" form, whose body and closing
+// delimiter may be indented with tabs.
+type heredoc struct {
+	Delimiter string
+	StripTabs bool
+}
+
+// closes reports whether body line closes the here-document.
+func (h heredoc) closes(line string) bool {
+	line = strings.TrimRight(line, "\r")
+	if h.StripTabs {
+		line = strings.TrimLeft(line, "\t")
+	}
+	return line == h.Delimiter
+}
+
+// incompleteShell reports whether text stops partway through a shell
+// statement: inside a quote, a $( or ( group, a compound command, or a
+// here-document, or right after a | or && waiting for the next command.
+// Text that is complete, or wrong in a way more lines cannot fix, is not.
+func incompleteShell(text string) bool {
+	_, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(text+"\n"), "")
+	return syntax.IsIncomplete(err)
+}
+
+// lineHeredocs does a light lexical pass over one logical shell line and
+// returns the here-documents it opens, in order. Quoted text and comments
+// are skipped, so `echo "<<EOF"` opens none.
+func lineHeredocs(line string) []heredoc {
+	var heredocs []heredoc
+	wordStart := true
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case c == '\\':
+			i++
+			wordStart = false
+
+		case c == '\'' || c == '"':
+			i = closingQuote(line, i)
+			wordStart = false
+
+		case c == '#' && wordStart:
+			return heredocs
+
+		case c == ' ' || c == '\t' || c == ';' || c == '&' || c == '|' || c == '(' || c == ')':
+			wordStart = true
+
+		case strings.HasPrefix(line[i:], "<<<"):
+			i += 2
+			wordStart = false
+
+		case strings.HasPrefix(line[i:], "<<"):
+			h, end := parseHeredoc(line, i+2)
+			if h.Delimiter != "" {
+				heredocs = append(heredocs, h)
+			}
+			i = end - 1
+			wordStart = false
+
+		default:
+			wordStart = false
+		}
+	}
+
+	return heredocs
+}
+
+// closingQuote returns the index of the quote that closes the one at
+// line[start], or the last index when the quote is unterminated.
+func closingQuote(line string, start int) int {
+	quote := line[start]
+	for i := start + 1; i < len(line); i++ {
+		if quote == '"' && line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == quote {
+			return i
+		}
+	}
+	return len(line) - 1
+}
+
+// parseHeredoc reads the delimiter following "<<" at line[start:]. It returns
+// the here-document and the index just past the delimiter word.
+func parseHeredoc(line string, start int) (heredoc, int) {
+	var h heredoc
+	i := start
+
+	if i < len(line) && line[i] == '-' {
+		h.StripTabs = true
+		i++
+	}
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+
+	var delim strings.Builder
+	for i < len(line) {
+		c := line[i]
+		if c == '\'' || c == '"' {
+			end := closingQuote(line, i)
+			delim.WriteString(strings.Trim(line[i:end+1], string(c)))
+			i = end + 1
+			continue
+		}
+		if strings.ContainsRune(" \t;&|<>()", rune(c)) {
+			break
+		}
+		if c == '\\' {
+			i++
+			continue
+		}
+		delim.WriteByte(c)
+		i++
+	}
+
+	// A delimiter starting with a digit is far more likely an arithmetic
+	// shift such as $((1<<2)) than a here-document.
+	if d := delim.String(); d != "" && (d[0] < '0' || d[0] > '9') {
+		h.Delimiter = d
+	}
+	return h, i
+}
+
+// fenceMarker returns the opening code-fence marker ("```" or "~~~", possibly
+// longer) of a Markdown fence line, or "" when line is not a fence.
+func fenceMarker(line string) string {
+	trimmed := strings.TrimSpace(line)
+	for _, ch := range []string{"`", "~"} {
+		if !strings.HasPrefix(trimmed, ch+ch+ch) {
+			continue
+		}
+		n := len(trimmed) - len(strings.TrimLeft(trimmed, ch))
+		return trimmed[:n]
+	}
+	return ""
+}
+
+// closesFence reports whether line closes a fence opened with marker.
+func closesFence(line, marker string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, marker) && strings.Trim(trimmed, marker[:1]) == ""
+}
@@ -0,0 +1,143 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// runSummary describes a run as a whole, for the footer of a report.
+type runSummary struct {
+	// Total is the wall-clock time of the run; see reportSpan.
+	Total time.Duration
+	// Slowest is the index of the action that took longest, or -1 when no
+	// action ran.
+	Slowest int
+	// UserTime and SystemTime add up the CPU time of every action; MaxRSS is
+	// the largest peak resident set size of any of them.
+	UserTime   time.Duration
+	SystemTime time.Duration
+	MaxRSS     int
+	// Hostname, Shell, and Dir are shared by every action that ran; each is
+	// empty when the actions disagree on it.
+	Hostname string
+	Shell    string
+	Dir      string
+}
+
+// summarize computes the summary of results. Skipped actions never ran, so
+// they are left out of everything but the total.
+func summarize(results []Result) runSummary {
+	s := runSummary{Total: reportSpan(results), Slowest: -1}
+
+	ran := 0
+	for i, r := range results {
+		if r.Skipped {
+			continue
+		}
+		if s.Slowest < 0 || r.Duration > results[s.Slowest].Duration {
+			s.Slowest = i
+		}
+		s.UserTime += r.UserTime
+		s.SystemTime += r.SystemTime
+		s.MaxRSS = max(s.MaxRSS, r.MaxRSS)
+
+		if ran == 0 {
+			s.Hostname, s.Shell, s.Dir = r.Hostname, r.Shell, r.Dir
+		}
+		s.Hostname = shared(s.Hostname, r.Hostname)
+		s.Shell = shared(s.Shell, r.Shell)
+		s.Dir = shared(s.Dir, r.Dir)
+		ran++
+	}
+
+	return s
+}
+
+// groupByHost splits results by Result.Hostname, keeping the order in which
+// hosts first appear and the order of results within a host. It returns a
+// single group when results are empty.
+func groupByHost(results []Result) [][]Result {
+	var hosts []string
+	groups := map[string][]Result{}
+	for _, r := range results {
+		if _, ok := groups[r.Hostname]; !ok {
+			hosts = append(hosts, r.Hostname)
+		}
+		groups[r.Hostname] = append(groups[r.Hostname], r)
+	}
+
+	if len(hosts) == 0 {
+		return [][]Result{results}
+	}
+	out := make([][]Result, len(hosts))
+	for i, host := range hosts {
+		out[i] = groups[host]
+	}
+	return out
+}
+
+// shared returns a when b is the same, and "" otherwise.
+func shared(a, b string) string {
+	if a != b {
+		return ""
+	}
+	return a
+}
+
+// reportSpan returns the wall-clock time covered by the results: from the
+// earliest start to the latest finish. Results without timestamps fall back to
+// the sum of their durations.
+func reportSpan(results []Result) time.Duration {
+	var first, last time.Time
+	var sum time.Duration
+
+	for _, r := range results {
+		sum += r.Duration
+		if r.StartedAt.IsZero() || r.FinishedAt.IsZero() {
+			continue
+		}
+		if first.IsZero() || r.StartedAt.Before(first) {
+			first = r.StartedAt
+		}
+		if r.FinishedAt.After(last) {
+			last = r.FinishedAt
+		}
+	}
+
+	if first.IsZero() {
+		return sum
+	}
+	return last.Sub(first)
+}
+
+// resourceNote describes CPU time and peak memory, e.g.
+// "1.2s user · 0.3s system · 45.0 MiB max RSS"; it returns "" when none were
+// reported.
+func resourceNote(userTime, systemTime time.Duration, maxRSS int) string {
+	if userTime == 0 && systemTime == 0 && maxRSS == 0 {
+		return ""
+	}
+	note := fmt.Sprintf("%s user · %s system", formatDuration(userTime), formatDuration(systemTime))
+	if maxRSS > 0 {
+		note += fmt.Sprintf(" · %s max RSS", formatBytes(maxRSS))
+	}
+	return note
+}
+
+// environmentNote describes where the actions of s ran, e.g.
+// "host build-01 · shell /bin/bash · cwd /src"; parts the actions disagree on
+// are left out.
+func environmentNote(s runSummary) string {
+	var parts []string
+	if s.Hostname != "" {
+		parts = append(parts, "host "+s.Hostname)
+	}
+	if s.Shell != "" {
+		parts = append(parts, "shell "+s.Shell)
+	}
+	if s.Dir != "" {
+		parts = append(parts, "cwd "+s.Dir)
+	}
+	return strings.Join(parts, " · ")
+}
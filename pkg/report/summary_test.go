@@ -0,0 +1,93 @@
+package report
+
+import (
+	"testing"
+	"time"
+)
+
+// metadataResults returns three results of one run on a single host and
+// shell: a fast action in the project directory, a slow one in /tmp, and a
+// skipped one.
+func metadataResults() []Result {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []Result{
+		{
+			Action:     Action{Command: "make lint"},
+			StartedAt:  start,
+			FinishedAt: start.Add(time.Second),
+			Duration:   time.Second,
+			UserTime:   800 * time.Millisecond,
+			SystemTime: 100 * time.Millisecond,
+			MaxRSS:     20 << 20,
+			Dir:        "/src/project",
+			Shell:      "/bin/bash",
+			Hostname:   "build-01",
+		},
+		{
+			Action:     Action{Command: "make test"},
+			StartedAt:  start.Add(time.Second),
+			FinishedAt: start.Add(4 * time.Second),
+			Duration:   3 * time.Second,
+			UserTime:   2 * time.Second,
+			SystemTime: 200 * time.Millisecond,
+			MaxRSS:     45 << 20,
+			Dir:        "/tmp",
+			Shell:      "/bin/bash",
+			Hostname:   "build-01",
+		},
+		{Action: Action{Command: "make deploy"}, ExitCode: -1, Skipped: true, BlockedBy: []string{"test"}},
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Result
+		want    runSummary
+	}{
+		{
+			name:    "totals, slowest, and shared environment",
+			results: metadataResults(),
+			want: runSummary{
+				Total:      4 * time.Second,
+				Slowest:    1,
+				UserTime:   2800 * time.Millisecond,
+				SystemTime: 300 * time.Millisecond,
+				MaxRSS:     45 << 20,
+				Hostname:   "build-01",
+				Shell:      "/bin/bash",
+			},
+		},
+		{
+			name:    "shared directory",
+			results: []Result{{Dir: "/src", Duration: time.Second}, {Dir: "/src", Duration: 2 * time.Second}},
+			want:    runSummary{Total: 3 * time.Second, Slowest: 1, Dir: "/src"},
+		},
+		{
+			name:    "nothing ran",
+			results: []Result{{Skipped: true}},
+			want:    runSummary{Slowest: -1},
+		},
+		{
+			name: "empty results",
+			want: runSummary{Slowest: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarize(tt.results); got != tt.want {
+				t.Errorf("summarize() = %+v\nwant          %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceNote(t *testing.T) {
+	if got := resourceNote(0, 0, 0); got != "" {
+		t.Errorf("resourceNote() = %q, want nothing when no usage was reported", got)
+	}
+	if got, want := resourceNote(1200*time.Millisecond, 300*time.Millisecond, 45<<20), "1.2s user · 300ms system · 45.0 MiB max RSS"; got != want {
+		t.Errorf("resourceNote() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around each change.
+	diffContext = 3
+	// diffMaxCells bounds the size of the LCS table; larger inputs are shown
+	// as a whole replacement instead of a line-level diff.
+	diffMaxCells = 4_000_000
+)
+
+// editKind is one step of a line-level edit script.
+type editKind int
+
+const (
+	editEqual editKind = iota
+	editDelete
+	editInsert
+)
+
+type edit struct {
+	kind editKind
+	line string
+}
+
+// unifiedDiff returns a unified diff of a and b, line by line, labelled with
+// oldName and newName. It returns "" when a and b are equal.
+func unifiedDiff(oldName, newName, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(edits); {
+		// Find the next change, then grow the hunk until a run of more than
+		// twice the context separates it from the following change.
+		first := start
+		for first < len(edits) && edits[first].kind == editEqual {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+
+		last := first
+		for i := first; i < len(edits); i++ {
+			if edits[i].kind != editEqual {
+				last = i
+			} else if i-last > 2*diffContext {
+				break
+			}
+		}
+
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(edits))
+		writeHunk(&out, edits, from, to)
+		start = to
+	}
+
+	return out.String()
+}
+
+// writeHunk writes edits[from:to] as one hunk with its @@ header.
+func writeHunk(out *strings.Builder, edits []edit, from, to int) {
+	oldStart, newStart := 1, 1
+	for _, e := range edits[:from] {
+		if e.kind != editInsert {
+			oldStart++
+		}
+		if e.kind != editDelete {
+			newStart++
+		}
+	}
+
+	var body strings.Builder
+	oldLen, newLen := 0, 0
+	for _, e := range edits[from:to] {
+		switch e.kind {
+		case editEqual:
+			oldLen++
+			newLen++
+			body.WriteString(" " + e.line + "\n")
+		case editDelete:
+			oldLen++
+			body.WriteString("-" + e.line + "\n")
+		case editInsert:
+			newLen++
+			body.WriteString("+" + e.line + "\n")
+		}
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n%s", hunkRange(oldStart, oldLen), hunkRange(newStart, newLen), body.String())
+}
+
+// hunkRange renders a hunk range the way diff -u does: an empty range
+// names the line before it.
+func hunkRange(start, length int) string {
+	if length == 0 {
+		start--
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+// diffLines computes an edit script turning a into b from their longest
+// common subsequence.
+func diffLines(a, b []string) []edit {
+	// Common prefix and suffix never need the table.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{editEqual, line})
+	}
+	edits = append(edits, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{editEqual, line})
+	}
+	return edits
+}
+
+// diffMiddle diffs a and b with a dynamic-programming LCS table.
+func diffMiddle(a, b []string) []edit {
+	var edits []edit
+
+	if len(a)*len(b) > diffMaxCells {
+		for _, line := range a {
+			edits = append(edits, edit{editDelete, line})
+		}
+		for _, line := range b {
+			edits = append(edits, edit{editInsert, line})
+		}
+		return edits
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, edit{editEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{editDelete, a[i]})
+			i++
+		default:
+			edits = append(edits, edit{editInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, edit{editDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, edit{editInsert, b[j]})
+	}
+	return edits
+}
+
+// splitLines splits s into lines, ignoring a trailing newline.
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
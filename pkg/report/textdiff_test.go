@@ -0,0 +1,74 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "equal", a: "a\nb\n", b: "a\nb\n", want: ""},
+		{
+			name: "single change with context",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "distant changes get separate hunks",
+			a:    "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			b:    "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+		{
+			name: "nearby changes share a hunk",
+			a:    "a\n1\n2\n3\n4\n5\n6\nb\n",
+			b:    "A\n1\n2\n3\n4\n5\n6\nB\n",
+			want: "--- old\n+++ new\n@@ -1,8 +1,8 @@\n-a\n+A\n 1\n 2\n 3\n 4\n 5\n 6\n-b\n+B\n",
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "x\ny\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "to empty",
+			a:    "x\n",
+			b:    "",
+			want: "--- old\n+++ new\n@@ -1 +0,0 @@\n-x\n",
+		},
+		{
+			name: "insertion in the middle",
+			a:    "a\nc\n",
+			b:    "a\nb\nc\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n+b\n c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", tt.a, tt.b); got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffLargeInput(t *testing.T) {
+	// Past diffMaxCells the diff falls back to a whole replacement.
+	a := strings.Repeat("a\n", 3000)
+	b := strings.Repeat("b\n", 3000)
+
+	got := unifiedDiff("old", "new", a, b)
+	if !strings.HasPrefix(got, "--- old\n+++ new\n@@ -1,3000 +1,3000 @@\n-a\n") {
+		t.Errorf("unexpected diff header: %q", got[:min(len(got), 60)])
+	}
+	if strings.Count(got, "\n+b") != 3000 {
+		t.Errorf("want 3000 inserted lines")
+	}
+}
@@ -0,0 +1,98 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Transport starts the processes that run actions: on this machine, or on
+// another one. ExecuteActions captures their output, enforces timeouts, and
+// kills them as it would a local shell.
+type Transport interface {
+	// Command returns the process that runs action's command, set up to be
+	// killed when ctx is done. shell is the local shell from ExecConfig.
+	Command(ctx context.Context, shell string, action Action) (*exec.Cmd, error)
+	// Host names the machine the commands run on, or "" for this one.
+	Host() string
+}
+
+// localTransport runs commands with the local shell. It is the Transport of
+// an ExecConfig that sets none.
+type localTransport struct{}
+
+func (localTransport) Command(ctx context.Context, shell string, action Action) (*exec.Cmd, error) {
+	if err := checkDir(action.Dir); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, shell, "-c", action.Command)
+	cmd.Dir = action.Dir
+	if len(action.Env) > 0 {
+		cmd.Env = append(os.Environ(), action.Env...)
+	}
+	return cmd, nil
+}
+
+func (localTransport) Host() string {
+	return ""
+}
+
+// SSHTransport runs commands on a remote host through the ssh client, which
+// reads the user's ssh configuration as usual. The remote login shell runs
+// each command, so it must be a POSIX shell; @cwd and @env apply on the
+// remote host. Killing an action for its timeout kills the local ssh client,
+// which closes the connection.
+type SSHTransport struct {
+	// Target is the destination, e.g. "deploy@web-1" or a Host alias.
+	Target string
+	// Program is the ssh client to run.
+	Program string
+	// Args are passed to Program before the destination.
+	Args []string
+}
+
+// NewSSHTransport returns a transport to target that runs ssh in batch mode,
+// so a missing key fails the action instead of prompting for a password.
+func NewSSHTransport(target string) (*SSHTransport, error) {
+	if target == "" || strings.HasPrefix(target, "-") || strings.ContainsAny(target, " \t\r\n") {
+		return nil, fmt.Errorf("invalid host: %q (expected [user@]host)", target)
+	}
+	return &SSHTransport{Target: target, Program: "ssh", Args: []string{"-o", "BatchMode=yes"}}, nil
+}
+
+func (t *SSHTransport) Command(ctx context.Context, shell string, action Action) (*exec.Cmd, error) {
+	args := append(slices.Clone(t.Args), "-T", "--", t.Target, remoteScript(action))
+	return exec.CommandContext(ctx, t.Program, args...), nil
+}
+
+func (t *SSHTransport) Host() string {
+	return t.Target
+}
+
+// remoteScript is the command line a remote shell runs for action: its
+// command, preceded by a cd and exports for its @cwd and @env.
+func remoteScript(action Action) string {
+	setup := setupScript(action)
+	if setup == "" {
+		return action.Command
+	}
+	return setup + " || exit\n" + action.Command
+}
+
+// setupScript returns the shell commands that apply action's working
+// directory and environment, joined with &&, or "" when it sets neither.
+func setupScript(action Action) string {
+	var setup []string
+	if action.Dir != "" {
+		setup = append(setup, "cd "+shellQuote(action.Dir))
+	}
+	for _, kv := range action.Env {
+		key, value, _ := strings.Cut(kv, "=")
+		setup = append(setup, "export "+key+"="+shellQuote(value))
+	}
+	return strings.Join(setup, " && ")
+}
@@ -0,0 +1,117 @@
+package report
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTransport stands in for a remote host: it runs commands with the local
+// shell, with HOST set to its name, and records what it was asked to run.
+type fakeTransport struct {
+	host string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func (f *fakeTransport) Command(ctx context.Context, shell string, action Action) (*exec.Cmd, error) {
+	f.mu.Lock()
+	f.commands = append(f.commands, action.Command)
+	f.mu.Unlock()
+
+	cmd := exec.CommandContext(ctx, shell, "-c", action.Command)
+	cmd.Env = append(os.Environ(), "HOST="+f.host)
+	return cmd, nil
+}
+
+func (f *fakeTransport) Host() string {
+	return f.host
+}
+
+func TestExecuteActionsTransport(t *testing.T) {
+	transport := &fakeTransport{host: "deploy@web-1"}
+	actions := []Action{
+		{Command: `echo "on $HOST"`, Dir: "/srv/app"},
+		{Name: "fail", Command: "exit 3"},
+		{Command: "echo never", Needs: []string{"fail"}},
+	}
+
+	got := ExecuteActions(context.Background(), actions, ExecConfig{Shell: "/bin/sh", Transport: transport})
+
+	if !reflect.DeepEqual(transport.commands, []string{actions[0].Command, actions[1].Command}) {
+		t.Errorf("transport ran %q, want the two actions that were not skipped", transport.commands)
+	}
+	if got[0].Output != "on deploy@web-1\n" {
+		t.Errorf("result[0].Output = %q, want the output of the transport", got[0].Output)
+	}
+	if got[0].Dir != "/srv/app" {
+		t.Errorf("result[0].Dir = %q, want the remote directory as given", got[0].Dir)
+	}
+	if got[1].ExitCode != 3 {
+		t.Errorf("result[1].ExitCode = %d, want 3", got[1].ExitCode)
+	}
+	for i, r := range got {
+		if r.Hostname != "deploy@web-1" || r.Shell != "" {
+			t.Errorf("result[%d] ran on %q with %q, want the transport's host and no local shell", i, r.Hostname, r.Shell)
+		}
+		if r.UserTime != 0 || r.MaxRSS != 0 {
+			t.Errorf("result[%d] reports the resource usage of the local client", i)
+		}
+	}
+}
+
+func TestSSHTransport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the ssh stand-in is a shell script")
+	}
+
+	// The stand-in drops ssh's options and destination and runs the remote
+	// command line with sh, as a remote login shell would.
+	dir := t.TempDir()
+	program := filepath.Join(dir, "ssh")
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\nshift 2\nexec /bin/sh -c \"$1\"\n"
+	if err := os.WriteFile(program, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	transport, err := NewSSHTransport("deploy@web-1")
+	if err != nil {
+		t.Fatalf("NewSSHTransport() returned unexpected error: %v", err)
+	}
+	transport.Program = program
+
+	action := Action{Command: `pwd; echo "$GREETING"`, Dir: dir, Env: []string{"GREETING=it's me"}}
+
+	cmd, err := transport.Command(context.Background(), "/bin/zsh", action)
+	if err != nil {
+		t.Fatalf("Command() returned unexpected error: %v", err)
+	}
+	wantArgs := []string{program, "-o", "BatchMode=yes", "-T", "--", "deploy@web-1", remoteScript(action)}
+	if !reflect.DeepEqual(cmd.Args, wantArgs) {
+		t.Errorf("Args = %q\nwant   %q", cmd.Args, wantArgs)
+	}
+
+	got := ExecuteActions(context.Background(), []Action{action, {Command: "cd /does/not/exist"}}, ExecConfig{Shell: "/bin/sh", Transport: transport})
+
+	if want := dir + "\nit's me\n"; got[0].Output != want {
+		t.Errorf("result[0].Output = %q, want %q", got[0].Output, want)
+	}
+	if got[1].ExitCode == 0 || !strings.Contains(got[1].Stderr, "/does/not/exist") {
+		t.Errorf("result[1] = exit %d, stderr %q; want the remote error", got[1].ExitCode, got[1].Stderr)
+	}
+}
+
+func TestNewSSHTransportErrors(t *testing.T) {
+	for _, target := range []string{"", "-oProxyCommand=evil", "web 1"} {
+		if _, err := NewSSHTransport(target); err == nil || !strings.Contains(err.Error(), "invalid host") {
+			t.Errorf("NewSSHTransport(%q) error = %v, want an invalid host error", target, err)
+		}
+	}
+}
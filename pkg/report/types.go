@@ -1,13 +1,23 @@
 package report
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
 
 // Format represents the output format for the report.
 type Format string
 
 const (
-	XML      Format = "xml"
-	Markdown Format = "md"
+	XML       Format = "xml"
+	Markdown  Format = "md"
+	JSON      Format = "json"
+	JSONLines Format = "jsonl"
+	JUnit     Format = "junit"
+	HTML      Format = "html"
 )
 
 // OnErrorBehavior controls what happens when a command fails.
@@ -18,14 +28,51 @@ const (
 	Stop     OnErrorBehavior = "stop"
 )
 
-// ParseFormat validates and returns a Format from a string.
+// ParseFormat validates and returns a Format from a string: a registered
+// format, or "template:PATH" for a user's template. The template file itself
+// is read by LookupFormatter.
 func ParseFormat(s string) (Format, error) {
-	switch Format(s) {
-	case XML, Markdown:
-		return Format(s), nil
-	default:
-		return "", fmt.Errorf("unsupported format: %q (expected %q or %q)", s, XML, Markdown)
+	f := Format(s)
+	if _, ok := formatters[f]; ok {
+		return f, nil
+	}
+	if _, ok := f.templatePath(); ok {
+		return f, nil
+	}
+	return "", unsupportedFormat(s)
+}
+
+// Extension returns the file name extension of reports in format f, e.g.
+// ".md"; see Formatter.Extension.
+func (f Format) Extension() string {
+	if formatter, ok := formatters[f]; ok {
+		return formatter.Extension()
 	}
+	if path, ok := f.templatePath(); ok {
+		return templateExtension(path)
+	}
+	return ".txt"
+}
+
+// ParseByteSize parses a size such as "512", "64KiB", "10M", or "1GB" into
+// bytes. Units are powers of 1024, whether written K, KB, or KiB.
+func ParseByteSize(s string) (int, error) {
+	text := strings.TrimSpace(s)
+	digits := strings.TrimRightFunc(text, unicode.IsLetter)
+	unit := strings.ToUpper(strings.TrimSpace(text[len(digits):]))
+
+	n, err := strconv.Atoi(strings.TrimSpace(digits))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q (expected a number of bytes such as 512, 64KiB, or 10MiB)", s)
+	}
+
+	multipliers := map[string]int{"": 1, "B": 1, "K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+		"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20, "G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30}
+	m, ok := multipliers[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size: %q (unknown unit %q)", s, text[len(digits):])
+	}
+	return n * m, nil
 }
 
 // ParseOnErrorBehavior validates and returns an OnErrorBehavior from a string.
@@ -38,15 +85,227 @@ func ParseOnErrorBehavior(s string) (OnErrorBehavior, error) {
 	}
 }
 
-// Action represents a parsed command with its description.
+// ExecConfig holds the settings that control how ExecuteActions runs actions.
+type ExecConfig struct {
+	// Shell runs each local action's command with -c.
+	Shell string
+	// Transport runs each action, on this machine when nil; sessions always
+	// run locally.
+	Transport Transport
+	// OnError set to Stop starts no more actions once one fails.
+	OnError OnErrorBehavior
+	// Session runs every action in one shared shell, serially, so shell
+	// state carries from one to the next.
+	Session bool
+	// Jobs is the maximum number of actions running at once; values below 1
+	// run actions serially.
+	Jobs int
+	// Timeout bounds each action's run time unless the action sets its own;
+	// zero means no limit.
+	Timeout time.Duration
+	// Retries is how many more times an action that did not pass is run,
+	// unless the action sets its own.
+	Retries int
+	// RetryDelay is the wait before the first retry; it doubles for each
+	// retry after that, capped at maxRetryDelay.
+	RetryDelay time.Duration
+	// MaxOutputBytes and MaxOutputLines cap the output kept for each action
+	// unless the action sets its own; zero means no limit.
+	MaxOutputBytes int
+	MaxOutputLines int
+	// OnStart, when set, is called with each action's input index as it
+	// starts. Calls to OnStart and OnResult are serialized.
+	OnStart func(index int, action Action)
+	// OnOutput, when set, receives each chunk an action writes to stdout or
+	// stderr as it arrives. Calls for one action are serialized, but
+	// different actions may call it concurrently.
+	OnOutput func(index int, chunk []byte)
+	// OnResult, when set, is called with each action's input index and
+	// result as soon as it finishes.
+	OnResult func(index int, result Result)
+}
+
+// Action represents a parsed command with its description and the options
+// set by its directives.
 type Action struct {
+	// Name identifies the action to the Needs of others.
+	Name string
+	// Needs names the actions that must pass before this one runs.
+	Needs       []string
 	Description string
 	Command     string
+	// A non-zero Timeout, Retries, MaxOutputBytes, or MaxOutputLines
+	// overrides its ExecConfig counterpart.
+	Timeout        time.Duration
+	Retries        int
+	MaxOutputBytes int
+	MaxOutputLines int
+	// ExpectExit is the exit code that counts as success.
+	ExpectExit int
+	// Dir is the working directory of the command.
+	Dir string
+	// Env lists extra KEY=value variables for the command.
+	Env []string
+	// AllowFailure keeps a failed action from failing the run.
+	AllowFailure bool
+	// Tags are the labels --tag selects actions by.
+	Tags []string
+	// Assertions are checked against the combined output of each run.
+	Assertions []Assertion
+	// Line is the 1-based line of the runbook fence the action was read from
+	// by ParseMarkdownActions, and 0 for actions from any other source.
+	Line int
+	// Matrix holds the axes of the action's @matrix directives until
+	// ExpandActions expands it.
+	Matrix []MatrixAxis
+	// MatrixValues lists the KEY=value pairs of one expansion.
+	MatrixValues []string
 }
 
 // Result represents the outcome of executing an Action.
 type Result struct {
-	Action   Action
+	Action Action
+	// ExitCode is -1 when the action was killed or skipped.
+	ExitCode int
+	// Output interleaves Stdout and Stderr in the order they were written.
+	Output string
+	Stdout string
+	Stderr string
+	// TimedOut is set when the action was killed for exceeding its timeout;
+	// Output then holds whatever was captured before the kill.
+	TimedOut   bool
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Duration   time.Duration
+	// UserTime and SystemTime are the CPU time the command, and the children
+	// it waited for, spent in user and kernel mode. They are zero when the
+	// platform does not report them and in session mode, where commands
+	// share one shell process.
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// MaxRSS is the peak resident set size of the command and its children,
+	// in bytes. It is zero in the same cases as UserTime, and on Linux also
+	// when it is no larger than the peak of this process, which every child
+	// starts from (see maxRSS).
+	MaxRSS int
+	// Dir is the working directory the command started in.
+	Dir string
+	// Shell is the shell that ran the command; empty for remote actions.
+	Shell string
+	// Hostname is the machine the command ran on.
+	Hostname string
+	// Assertions holds the outcome of each of the action's assertions, in
+	// declaration order.
+	Assertions []AssertionResult
+	// Attempts lists every attempt of a retried action in order; the rest of
+	// the result describes the last one. It is empty for results that were
+	// not produced by ExecuteActions.
+	Attempts []Attempt
+	// Skipped is set when the action never ran because some of its needs,
+	// listed in BlockedBy, did not pass.
+	Skipped   bool
+	BlockedBy []string
+	// Truncated is set when output went over the limits; OutputSize and
+	// OutputLines then give the size of the whole combined output. See
+	// ExecConfig.MaxOutputBytes.
+	Truncated   bool
+	OutputSize  int
+	OutputLines int
+	// Redactions counts the secrets a Redactor masked in the result.
+	Redactions int
+}
+
+// Attempt records one run of an action that may have been retried.
+type Attempt struct {
 	ExitCode int
+	TimedOut bool
 	Output   string
+	Duration time.Duration
+}
+
+// AttemptCount returns how many times the action ran; results that carry no
+// attempts count as a single run.
+func (r Result) AttemptCount() int {
+	return max(1, len(r.Attempts))
+}
+
+// Passed reports whether the action ran to completion, exited with its
+// expected code, and satisfied all of its assertions.
+func (r Result) Passed() bool {
+	return !r.Skipped && !r.TimedOut && r.ExitCode == r.Action.ExpectExit && len(r.FailedAssertions()) == 0
+}
+
+// Failed reports whether the result counts as a failure of the run: the
+// action ran, did not pass, and does not allow failure. A skipped action is
+// not a failure of its own; the need that blocked it already is.
+func (r Result) Failed() bool {
+	return !r.Skipped && !r.Passed() && !r.Action.AllowFailure
+}
+
+// CountFailed returns how many results count as failures of the run.
+func CountFailed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Failed() {
+			n++
+		}
+	}
+	return n
+}
+
+// CountSkipped returns how many results were skipped.
+func CountSkipped(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Skipped {
+			n++
+		}
+	}
+	return n
+}
+
+// EffectiveTimeout returns the timeout that applies to action under cfg.
+func (cfg ExecConfig) EffectiveTimeout(action Action) time.Duration {
+	if action.Timeout > 0 {
+		return action.Timeout
+	}
+	return cfg.Timeout
+}
+
+// EffectiveRetries returns the number of retries that applies to action under cfg.
+func (cfg ExecConfig) EffectiveRetries(action Action) int {
+	if action.Retries > 0 {
+		return action.Retries
+	}
+	return max(0, cfg.Retries)
+}
+
+// transport returns cfg.Transport, or the local one when it is unset.
+func (cfg ExecConfig) transport() Transport {
+	if cfg.Transport == nil {
+		return localTransport{}
+	}
+	return cfg.Transport
+}
+
+// EffectiveOutputLimits returns the output byte and line limits that apply
+// to action under cfg.
+func (cfg ExecConfig) EffectiveOutputLimits(action Action) (maxBytes, maxLines int) {
+	maxBytes, maxLines = cfg.MaxOutputBytes, cfg.MaxOutputLines
+	if action.MaxOutputBytes > 0 {
+		maxBytes = action.MaxOutputBytes
+	}
+	if action.MaxOutputLines > 0 {
+		maxLines = action.MaxOutputLines
+	}
+	return maxBytes, maxLines
+}
+
+// retryDelay returns how long to wait before retry n, counting from 1.
+func (cfg ExecConfig) retryDelay(n int) time.Duration {
+	delay := cfg.RetryDelay
+	for i := 1; i < n && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
 }
@@ -0,0 +1,241 @@
+package report
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// varName matches the names of template variables and @matrix keys: names a
+// template can reach as {{ .Name }}.
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// MatrixAxis is one KEY=value,value axis of a @matrix directive.
+type MatrixAxis struct {
+	Key    string
+	Values []string
+}
+
+// ParseVar splits a KEY=value pair as given to --var.
+func ParseVar(kv string) (string, string, error) {
+	key, value, ok := strings.Cut(kv, "=")
+	if !ok || !varName.MatchString(key) {
+		return "", "", fmt.Errorf("invalid variable %q (expected KEY=value, with KEY a letter or underscore followed by letters, digits, or underscores)", kv)
+	}
+	return key, value, nil
+}
+
+// ParseVarsFile reads a vars file: a YAML mapping of variable names to
+// scalar values, such as "namespace: staging".
+func ParseVarsFile(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("yaml unmarshal: %w", err)
+	}
+
+	vars := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if !varName.MatchString(key) {
+			return nil, fmt.Errorf("invalid variable name %q", key)
+		}
+		switch value.(type) {
+		case nil:
+			vars[key] = ""
+		case map[interface{}]interface{}, []interface{}:
+			return nil, fmt.Errorf("variable %q is not a scalar", key)
+		default:
+			vars[key] = fmt.Sprint(value)
+		}
+	}
+	return vars, nil
+}
+
+// EnvVars returns the variables of environ (KEY=value pairs, as from
+// os.Environ) whose names a template can reach.
+func EnvVars(environ []string) map[string]string {
+	vars := map[string]string{}
+	for _, kv := range environ {
+		if key, value, err := ParseVar(kv); err == nil {
+			vars[key] = value
+		}
+	}
+	return vars
+}
+
+// ExpandActions expands every action with a @matrix into one action per
+// combination of its axis values, the first axis varying slowest, and fills
+// the {{ .Var }} placeholders of the description, command, @name, @needs,
+// @tag, @cwd, @env, and output assertions. Placeholders read vars, and in
+// matrix actions the axis values, which win over vars.
+//
+// Actions without a @matrix are only filled when fill is set, so inputs that
+// contain Go template syntax of their own, such as docker --format strings,
+// run unchanged unless variables were asked for. An expanded action's
+// description ends with its axis values, e.g. "Deploy (region=us)", and
+// MatrixValues lists them.
+//
+// Names may differ between expansions, as in "@name build-{{.region}}", so
+// the dependency graph is checked again once the actions are expanded.
+func ExpandActions(actions []Action, vars map[string]string, fill bool) ([]Action, error) {
+	expanded := []Action{}
+
+	for _, action := range actions {
+		if len(action.Matrix) == 0 {
+			if fill {
+				filled, err := fillAction(action, vars)
+				if err != nil {
+					return nil, err
+				}
+				action = filled
+			}
+			expanded = append(expanded, action)
+			continue
+		}
+
+		for _, values := range matrixCombinations(action.Matrix) {
+			data := make(map[string]string, len(vars)+len(values))
+			for key, value := range vars {
+				data[key] = value
+			}
+
+			pairs := make([]string, len(values))
+			for i, value := range values {
+				key := action.Matrix[i].Key
+				data[key] = value
+				pairs[i] = key + "=" + value
+			}
+
+			filled, err := fillAction(action, data)
+			if err != nil {
+				return nil, err
+			}
+
+			filled.Matrix = nil
+			filled.MatrixValues = pairs
+			if filled.Description != "" {
+				filled.Description += " (" + strings.Join(pairs, ", ") + ")"
+			} else {
+				filled.Description = strings.Join(pairs, ", ")
+			}
+			expanded = append(expanded, filled)
+		}
+	}
+
+	if err := checkNeeds(expanded); err != nil {
+		return nil, err
+	}
+
+	return expanded, nil
+}
+
+// matrixCombinations returns every combination of one value per axis, the
+// first axis varying slowest.
+func matrixCombinations(axes []MatrixAxis) [][]string {
+	combinations := [][]string{{}}
+	for _, axis := range axes {
+		var next [][]string
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				next = append(next, append(slices.Clone(combination), value))
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// fillAction returns action with the placeholders of its text fields filled
+// from data.
+func fillAction(action Action, data map[string]string) (Action, error) {
+	label := actionLabel(action)
+
+	fill := func(field, text string) (string, error) {
+		out, err := fillTemplate(field, text, data)
+		if err != nil {
+			return "", fmt.Errorf("%q: %w", label, err)
+		}
+		return out, nil
+	}
+
+	fillAll := func(field string, texts []string) ([]string, error) {
+		if len(texts) == 0 {
+			return texts, nil
+		}
+		out := make([]string, len(texts))
+		for i, text := range texts {
+			filled, err := fill(field, text)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = filled
+		}
+		return out, nil
+	}
+
+	var err error
+	if action.Description, err = fill("description", action.Description); err != nil {
+		return Action{}, err
+	}
+	if action.Command, err = fill("command", action.Command); err != nil {
+		return Action{}, err
+	}
+	if action.Name, err = fill("name", action.Name); err != nil {
+		return Action{}, err
+	}
+	if action.Name != "" && len(strings.Fields(action.Name)) != 1 {
+		return Action{}, fmt.Errorf("%q: invalid @name %q (expected a single word)", label, action.Name)
+	}
+	if action.Dir, err = fill("cwd", action.Dir); err != nil {
+		return Action{}, err
+	}
+	if action.Needs, err = fillAll("needs", action.Needs); err != nil {
+		return Action{}, err
+	}
+	if action.Tags, err = fillAll("tag", action.Tags); err != nil {
+		return Action{}, err
+	}
+	if action.Env, err = fillAll("env", action.Env); err != nil {
+		return Action{}, err
+	}
+
+	if len(action.Assertions) > 0 {
+		assertions := make([]Assertion, len(action.Assertions))
+		for i, a := range action.Assertions {
+			pattern, err := fill("assertion", a.Pattern)
+			if err != nil {
+				return Action{}, err
+			}
+			assertion, err := newAssertion(a.Kind, pattern)
+			if err != nil {
+				return Action{}, fmt.Errorf("%q: invalid assertion %q: %w", label, pattern, err)
+			}
+			assertions[i] = assertion
+		}
+		action.Assertions = assertions
+	}
+
+	return action, nil
+}
+
+// fillTemplate executes text as a Go template over data. A placeholder for a
+// variable that data lacks is an error rather than an empty string.
+func fillTemplate(name, text string, data map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,158 @@
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandActions(t *testing.T) {
+	vars := map[string]string{"namespace": "staging", "region": "ignored"}
+
+	tests := []struct {
+		name   string
+		input  string
+		fill   bool
+		verify func(t *testing.T, got []Action)
+	}{
+		{
+			name:  "placeholders are filled from vars",
+			input: "# Pods in {{ .namespace }}\n# @cwd /srv/{{ .namespace }}\n# @env NS={{ .namespace }}\n# @expect-output-contains {{ .namespace }}\nkubectl -n {{ .namespace }} get pods",
+			fill:  true,
+			verify: func(t *testing.T, got []Action) {
+				want := Action{
+					Description: "Pods in staging",
+					Command:     "kubectl -n staging get pods",
+					Dir:         "/srv/staging",
+					Env:         []string{"NS=staging"},
+					Assertions:  []Assertion{{Kind: OutputContains, Pattern: "staging"}},
+				}
+				if !reflect.DeepEqual(got[0], want) {
+					t.Errorf("action = %+v\nwant     %+v", got[0], want)
+				}
+			},
+		},
+		{
+			name:  "without fill templates are kept",
+			input: "docker ps --format '{{.ID}}'",
+			verify: func(t *testing.T, got []Action) {
+				if got[0].Command != "docker ps --format '{{.ID}}'" {
+					t.Errorf("Command = %q, want it unchanged", got[0].Command)
+				}
+			},
+		},
+		{
+			name:  "matrix expands in order and wins over vars",
+			input: "# Ping\n# @matrix region=us,eu tier=web,db\nping {{ .tier }}.{{ .region }}.{{ .namespace }}",
+			verify: func(t *testing.T, got []Action) {
+				var commands, descriptions []string
+				for _, a := range got {
+					commands = append(commands, a.Command)
+					descriptions = append(descriptions, a.Description)
+				}
+				wantCommands := []string{"ping web.us.staging", "ping db.us.staging", "ping web.eu.staging", "ping db.eu.staging"}
+				if !reflect.DeepEqual(commands, wantCommands) {
+					t.Errorf("commands = %q, want %q", commands, wantCommands)
+				}
+				if descriptions[1] != "Ping (region=us, tier=db)" {
+					t.Errorf("description = %q, want the matrix values appended", descriptions[1])
+				}
+				if !reflect.DeepEqual(got[1].MatrixValues, []string{"region=us", "tier=db"}) || got[1].Matrix != nil {
+					t.Errorf("MatrixValues = %q, Matrix = %v; want the expansion's values and no axes", got[1].MatrixValues, got[1].Matrix)
+				}
+			},
+		},
+		{
+			name:  "matrix without a description",
+			input: "# @matrix n=1,2\necho {{ .n }}",
+			verify: func(t *testing.T, got []Action) {
+				if got[0].Description != "n=1" || got[1].Description != "n=2" {
+					t.Errorf("descriptions = %q, %q; want the matrix values", got[0].Description, got[1].Description)
+				}
+			},
+		},
+		{
+			name:  "templated names keep needs apart",
+			input: "# @matrix region=us,eu\n# @name build-{{.region}}\nmake {{ .region }}\n\n# @matrix region=us,eu\n# @needs build-{{.region}}\ndeploy {{ .region }}",
+			verify: func(t *testing.T, got []Action) {
+				if got[1].Name != "build-eu" || !reflect.DeepEqual(got[3].Needs, []string{"build-eu"}) {
+					t.Errorf("got %+v, want names and needs filled per region", got)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actions, err := ParseActions(tt.input)
+			if err != nil {
+				t.Fatalf("ParseActions() returned unexpected error: %v", err)
+			}
+			got, err := ExpandActions(actions, vars, tt.fill)
+			if err != nil {
+				t.Fatalf("ExpandActions() returned unexpected error: %v", err)
+			}
+			tt.verify(t, got)
+		})
+	}
+}
+
+func TestExpandActionsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "undefined variable", input: "echo {{ .missing }}", want: `map has no entry for key "missing"`},
+		{name: "invalid template", input: "echo {{ .x", want: `"echo {{ .x": template: command`},
+		{name: "duplicate expanded name", input: "# @matrix n=1,2\n# @name same\necho {{ .n }}", want: `duplicate action name "same"`},
+		{name: "filled name is not a word", input: "# @name {{.y}}\necho", want: `invalid @name "a b"`},
+		{name: "invalid filled regexp", input: "# @expect-output-match {{ .x }}\necho", want: `invalid assertion "("`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actions, err := ParseActions(tt.input)
+			if err != nil {
+				t.Fatalf("ParseActions() returned unexpected error: %v", err)
+			}
+			_, err = ExpandActions(actions, map[string]string{"x": "(", "y": "a b"}, true)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ExpandActions() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVar(t *testing.T) {
+	if key, value, err := ParseVar("url=https://x?a=b"); err != nil || key != "url" || value != "https://x?a=b" {
+		t.Errorf("ParseVar() = %q, %q, %v; want url and the rest of the pair", key, value, err)
+	}
+	for _, kv := range []string{"novalue", "=x", "my-var=x", "1st=x"} {
+		if _, _, err := ParseVar(kv); err == nil {
+			t.Errorf("ParseVar(%q) returned nil error", kv)
+		}
+	}
+}
+
+func TestParseVarsFile(t *testing.T) {
+	got, err := ParseVarsFile([]byte("namespace: staging\nreplicas: 3\ndebug: true\nempty:\n"))
+	if err != nil {
+		t.Fatalf("ParseVarsFile() returned unexpected error: %v", err)
+	}
+	want := map[string]string{"namespace": "staging", "replicas": "3", "debug": "true", "empty": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseVarsFile() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseVarsFile([]byte("hosts: [a, b]\n")); err == nil || !strings.Contains(err.Error(), `variable "hosts" is not a scalar`) {
+		t.Errorf("ParseVarsFile() error = %v, want a not a scalar error", err)
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	got := EnvVars([]string{"HOME=/home/me", "=C:=C:\\", "weird-name=x"})
+	if !reflect.DeepEqual(got, map[string]string{"HOME": "/home/me"}) {
+		t.Errorf("EnvVars() = %v, want only names a template can reach", got)
+	}
+}
@@ -16,11 +16,13 @@ type Styles struct {
 	ErrorHeader,
 	ErrorDetails,
 	ErrPadding,
+	Failed,
 	Flag,
 	FlagComma,
 	FlagDesc,
 	InlineCode,
 	Link,
+	Passed,
 	Pipe,
 	Quote,
 	ConversationList,
@@ -38,11 +40,13 @@ func MakeStyles(r *lipgloss.Renderer) (s Styles) {
 	s.ErrorHeader = r.NewStyle().Foreground(lipgloss.Color("#c0caf5")).Background(lipgloss.Color("#f7768e")).Bold(true).Padding(0, 1).SetString("ERROR")
 	s.ErrorDetails = s.Comment
 	s.ErrPadding = r.NewStyle().Padding(0, horizontalEdgePadding)
+	s.Failed = r.NewStyle().Foreground(lipgloss.Color("#f7768e")).Bold(true)
 	s.Flag = r.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#41a6b5", Dark: "#7dcfff"}).Bold(true)
 	s.FlagComma = r.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#89ddff", Dark: "#89ddff"}).SetString(",")
 	s.FlagDesc = s.Comment
 	s.InlineCode = r.NewStyle().Foreground(lipgloss.Color("#f7768e")).Background(lipgloss.Color("#1a1b26")).Padding(0, 1)
 	s.Link = r.NewStyle().Foreground(lipgloss.Color("#7aa2f7")).Underline(true)
+	s.Passed = r.NewStyle().Foreground(lipgloss.Color("#9ece6a")).Bold(true)
 	s.Quote = r.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#bb9af7", Dark: "#bb9af7"})
 	s.Pipe = r.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#7dcfff", Dark: "#7dcfff"})
 	s.ConversationList = r.NewStyle().Padding(0, 1)
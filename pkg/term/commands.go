@@ -40,3 +40,8 @@ func ClearFromCursor() error {
 	_, err := fmt.Fprint(os.Stdout, "\033[J")
 	return err
 }
+
+// GetSize returns the width and height of the terminal attached to stdout.
+func GetSize() (width, height int, err error) {
+	return term.GetSize(int(os.Stdout.Fd()))
+}